@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"filament-sync-tool/cli/baseline"
+	"filament-sync-tool/cli/creality"
+	"filament-sync-tool/cli/scp"
+)
+
+// baselineCacheDir is where "baseline refresh" stores stock databases pulled from printers.
+func baselineCacheDir() string {
+	return filepath.Join(appConfig.DataDir, "baselines")
+}
+
+// loadBaseline replaces the embedded baseline loaded in init() when a better one is available.
+// Precedence: --baseline-file / --baseline-dir, then the newest cached baseline matching the
+// printer's firmware, then the embedded data.
+func loadBaseline(scpClient *scp.SCPClient) {
+	var databaseData, optionsData []byte
+	var source string
+	var err error
+
+	switch {
+	case appConfig.BaselineFile != "":
+		databaseData, err = os.ReadFile(appConfig.BaselineFile)
+		if err != nil {
+			log.Fatalf("Failed to read baseline file: %v", err)
+		}
+		source = appConfig.BaselineFile
+	case appConfig.BaselineDir != "":
+		databaseData, optionsData, err = baseline.ReadDir(appConfig.BaselineDir)
+		if err != nil {
+			log.Fatalf("Failed to read baseline directory %s: %v", appConfig.BaselineDir, err)
+		}
+		source = appConfig.BaselineDir
+	default:
+		snapshot := cachedBaseline(scpClient)
+		if snapshot == nil {
+			log.Println("Using the embedded baseline database.")
+			return
+		}
+		databaseData, optionsData, err = snapshot.Read()
+		if err != nil {
+			log.Printf("Warning: ignoring cached baseline %s: %v", snapshot.Dir, err)
+			log.Println("Using the embedded baseline database.")
+			return
+		}
+		source = fmt.Sprintf("cached baseline for firmware %s from %s", snapshot.Firmware, snapshot.FetchedAt.Format(time.RFC3339))
+	}
+
+	db, err := creality.LoadDefaultDatabaseFromBytes(databaseData)
+	if err != nil {
+		log.Fatalf("Failed to load baseline database from %s: %v", source, err)
+	}
+	materialDB = db
+
+	// Without an options file the embedded options are kept
+	if optionsData != nil {
+		options, err := creality.LoadDefaultOptionsFromBytes(optionsData)
+		if err != nil {
+			log.Fatalf("Failed to load baseline options from %s: %v", source, err)
+		}
+		materialOptions = options
+	}
+	log.Printf("Using baseline database from %s (%d entries).", source, len(materialDB.Result.List))
+}
+
+// cachedBaseline returns the newest cached baseline matching the printer's firmware, or nil.
+func cachedBaseline(scpClient *scp.SCPClient) *baseline.Snapshot {
+	firmware, err := printerModel.FirmwareVersion(scpClient)
+	if err != nil {
+		log.Printf("Warning: cannot match a cached baseline: %v", err)
+		return nil
+	}
+
+	snapshot, err := baseline.Latest(baselineCacheDir(), printerModel.Name, firmware)
+	if err != nil {
+		log.Printf("Warning: cannot read the baseline cache: %v", err)
+		return nil
+	}
+	if snapshot == nil {
+		log.Printf("No cached baseline for firmware %s; run \"baseline refresh\" to create one.", firmware)
+	}
+	return snapshot
+}
+
+// runBaseline dispatches the "baseline" subcommands.
+func runBaseline() {
+	subcommand := ""
+	if len(appConfig.Args) > 0 {
+		subcommand = appConfig.Args[0]
+	}
+
+	switch subcommand {
+	case "refresh":
+		refreshBaseline()
+	case "list":
+		listBaselines()
+	default:
+		log.Fatalf("Unknown baseline subcommand %q, expected \"refresh\" or \"list\"", subcommand)
+	}
+}
+
+// refreshBaseline pulls the stock entries from the printer into the local baseline cache.
+func refreshBaseline() {
+	if appConfig.PrinterIP == "" {
+		log.Fatalf("baseline refresh requires --printer-ip")
+	}
+
+	scpClient := connectPrinter()
+	defer scpClient.Close()
+
+	firmware, err := printerModel.FirmwareVersion(scpClient)
+	if err != nil {
+		log.Fatalf("Cannot refresh baseline: %v", err)
+	}
+
+	databaseData, err := scpClient.ReadFile(filepath.Join(printerModel.BoxDir, "material_database.json"))
+	if err != nil {
+		log.Fatalf("Failed to download the printer material database: %v", err)
+	}
+	optionsData, err := scpClient.ReadFile(filepath.Join(printerModel.BoxDir, "material_option.json"))
+	if err != nil {
+		log.Fatalf("Failed to download the printer material options: %v", err)
+	}
+
+	printerDB, err := creality.LoadDefaultDatabaseFromBytes(databaseData)
+	if err != nil {
+		log.Fatalf("Failed to load the printer material database: %v", err)
+	}
+	printerOptions, err := creality.LoadDefaultOptionsFromBytes(optionsData)
+	if err != nil {
+		log.Fatalf("Failed to load the printer material options: %v", err)
+	}
+
+	// Keep only what the firmware shipped, not what earlier syncs added
+	stockDB := creality.StockDatabase(printerDB)
+	stockOptions := creality.StockOptions(printerOptions, printerDB)
+
+	stockDBBytes, err := creality.MarshalDatabase(stockDB)
+	if err != nil {
+		log.Fatalf("Failed to marshal baseline database: %v", err)
+	}
+	stockOptBytes, err := creality.MarshalOptions(stockOptions)
+	if err != nil {
+		log.Fatalf("Failed to marshal baseline options: %v", err)
+	}
+
+	snapshot, err := baseline.Store(baselineCacheDir(), baseline.Snapshot{
+		Model:     printerModel.Name,
+		Firmware:  firmware,
+		Source:    appConfig.PrinterIP,
+		FetchedAt: time.Now().UTC(),
+		Entries:   len(stockDB.Result.List),
+	}, stockDBBytes, stockOptBytes)
+	if err != nil {
+		log.Fatalf("Failed to store baseline: %v", err)
+	}
+
+	log.Printf("Stored baseline with %d stock entries (skipped %d synced entries) for firmware %s in %s",
+		len(stockDB.Result.List), len(printerDB.Result.List)-len(stockDB.Result.List), firmware, snapshot.Dir)
+}
+
+// listBaselines prints the cached baselines for the selected model.
+func listBaselines() {
+	snapshots, err := baseline.List(baselineCacheDir(), printerModel.Name)
+	if err != nil {
+		log.Fatalf("Failed to list baselines: %v", err)
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("No cached baselines for %s in %s\n", printerModel.DisplayName, baselineCacheDir())
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIRMWARE\tFETCHED\tSOURCE\tENTRIES\tDIRECTORY")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", snapshot.Firmware, snapshot.FetchedAt.Format(time.RFC3339), snapshot.Source, snapshot.Entries, snapshot.Dir)
+	}
+	w.Flush()
+}
@@ -0,0 +1,125 @@
+package baseline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	databaseFile = "material_database.json"
+	optionsFile  = "material_option.json"
+	metaFile     = "baseline.json"
+
+	// timestampLayout names snapshot directories so they sort chronologically.
+	timestampLayout = "20060102-150405"
+)
+
+// unsafeChars is replaced in firmware versions used as directory names.
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Snapshot is one cached copy of a printer's stock material database and options.
+type Snapshot struct {
+	Model     string    `json:"model"`
+	Firmware  string    `json:"firmware"`
+	Source    string    `json:"source"` // Printer host the snapshot was taken from
+	FetchedAt time.Time `json:"fetchedAt"`
+	Entries   int       `json:"entries"`
+	Dir       string    `json:"-"`
+}
+
+// Store writes a new snapshot under root/<model>/<firmware>/<timestamp>.
+func Store(root string, snapshot Snapshot, databaseData, optionsData []byte) (*Snapshot, error) {
+	snapshot.Dir = filepath.Join(root, snapshot.Model, firmwareDir(snapshot.Firmware), snapshot.FetchedAt.Format(timestampLayout))
+	if err := os.MkdirAll(snapshot.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create baseline directory %s: %w", snapshot.Dir, err)
+	}
+
+	meta, err := json.MarshalIndent(snapshot, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal baseline metadata: %w", err)
+	}
+
+	files := map[string][]byte{databaseFile: databaseData, optionsFile: optionsData, metaFile: meta}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(snapshot.Dir, name), data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write baseline %s: %w", name, err)
+		}
+	}
+	return &snapshot, nil
+}
+
+// List returns every cached snapshot for model, newest first.
+func List(root, model string) ([]Snapshot, error) {
+	metaPaths, err := filepath.Glob(filepath.Join(root, model, "*", "*", metaFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list baselines: %w", err)
+	}
+
+	var snapshots []Snapshot
+	for _, metaPath := range metaPaths {
+		data, err := os.ReadFile(metaPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read baseline metadata %s: %w", metaPath, err)
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to parse baseline metadata %s: %w", metaPath, err)
+		}
+		snapshot.Dir = filepath.Dir(metaPath)
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].FetchedAt.After(snapshots[j].FetchedAt) })
+	return snapshots, nil
+}
+
+// Latest returns the newest cached snapshot for model taken on the given firmware, or nil.
+func Latest(root, model, firmware string) (*Snapshot, error) {
+	snapshots, err := List(root, model)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Firmware == firmware {
+			return &snapshot, nil
+		}
+	}
+	return nil, nil
+}
+
+// Read returns the snapshot's material database and options.
+func (s *Snapshot) Read() ([]byte, []byte, error) {
+	return ReadDir(s.Dir)
+}
+
+// ReadDir reads material_database.json and material_option.json from dir.
+// The options file is optional; nil is returned for it when it is missing.
+func ReadDir(dir string) ([]byte, []byte, error) {
+	databaseData, err := os.ReadFile(filepath.Join(dir, databaseFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read baseline database: %w", err)
+	}
+	optionsData, err := os.ReadFile(filepath.Join(dir, optionsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return databaseData, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read baseline options: %w", err)
+	}
+	return databaseData, optionsData, nil
+}
+
+// firmwareDir turns a firmware version into a safe directory name.
+func firmwareDir(firmware string) string {
+	name := unsafeChars.ReplaceAllString(firmware, "_")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
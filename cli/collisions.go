@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log"
+
+	"filament-sync-tool/cli/creality"
+	"filament-sync-tool/cli/profiles"
+)
+
+// idCollision is a local profile whose ID is already taken.
+type idCollision struct {
+	Profile string // Path of the local profile
+	ID      string
+	TakenBy string // What holds the ID: another profile, or a stock filament
+}
+
+// findCollisions lists the IDs of local profiles that are taken by a stock filament in the
+// baseline or on the printer, plus the duplicate IDs among the local profiles.
+// printerDB may be nil when the printer's database could not be read.
+func findCollisions(localProfiles []localProfile, duplicates []profiles.Duplicate, printerDB *creality.MaterialDatabase) []idCollision {
+	var collisions []idCollision
+	for _, duplicate := range duplicates {
+		collisions = append(collisions, idCollision{Profile: duplicate.Path, ID: duplicate.ID, TakenBy: "profile " + duplicate.Winner})
+	}
+
+	for _, profile := range localProfiles {
+		id := profile.Entry.Base.ID
+		if stock := creality.FindStockEntry(materialDB, id); stock != nil {
+			collisions = append(collisions, idCollision{Profile: profile.Path, ID: id, TakenBy: "stock filament " + stock.Base.Brand + " " + stock.Base.Name})
+			continue
+		}
+		// Stock filaments added by a firmware update are only known to the printer
+		if printerDB != nil {
+			if stock := creality.FindStockEntry(printerDB, id); stock != nil {
+				collisions = append(collisions, idCollision{Profile: profile.Path, ID: id, TakenBy: "printer filament " + stock.Base.Brand + " " + stock.Base.Name})
+			}
+		}
+	}
+	return collisions
+}
+
+// applyCollisionPolicy handles ID collisions according to --on-collision and returns the
+// profiles to sync:
+//   - error: stop without syncing anything
+//   - warn: skip the colliding profiles
+//   - override: let the profiles replace the stock filaments
+//
+// Duplicates among local profiles were already resolved by precedence, so under warn and
+// override they are only reported.
+func applyCollisionPolicy(localProfiles []localProfile, duplicates []profiles.Duplicate, printerDB *creality.MaterialDatabase) []localProfile {
+	collisions := findCollisions(localProfiles, duplicates, printerDB)
+	if len(collisions) == 0 {
+		return localProfiles
+	}
+
+	colliding := make(map[string]bool)
+	for _, collision := range collisions {
+		log.Printf("ID collision: %s uses id %s, which is taken by %s", collision.Profile, collision.ID, collision.TakenBy)
+		colliding[collision.Profile] = true
+	}
+
+	switch appConfig.OnCollision {
+	case "error":
+		log.Fatalf("Sync stopped: %d ID collisions. Give the profiles unique IDs (see \"ids assign\"), or use --on-collision warn or override.", len(collisions))
+	case "override":
+		log.Println("--on-collision override set: profiles replace the stock filaments they collide with.")
+		return localProfiles
+	}
+
+	var kept []localProfile
+	for _, profile := range localProfiles {
+		if colliding[profile.Path] {
+			log.Printf("Skipping profile %s: its id collides, use --on-collision override to replace the stock filament", profile.Path)
+			continue
+		}
+		kept = append(kept, profile)
+	}
+	return kept
+}
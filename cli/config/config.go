@@ -1,18 +1,163 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"filament-sync-tool/cli/printer"
+	"filament-sync-tool/cli/profiles"
 )
 
 // ToolConfig holds the application-wide configuration parameters from command-line flags.
 type ToolConfig struct {
-	PrinterIP   string
-	User        string
-	Password    string
-	ProfilePath string
+	Command      string   // Subcommand to run, "sync" when none is given
+	Args         []string // Positional arguments left after the flags
+	PrinterIP    string
+	User         string
+	Password     string
+	ProfilePaths []string      // Profile directories in precedence order, globs and "~" expanded
+	Recursive    bool          // Scan profile directories recursively
+	Force        bool          // Sync even when the printer reports an active job
+	Wait         time.Duration // How long to wait for an active job to finish before postponing
+	StatusURL    string        // Base URL of the printer status API; empty means derive from PrinterIP
+	ConfigPath   string        // Configuration file that was loaded, empty if none
+	Collection   string        // Named collection selected with --collection
+	Include      []string      // Include filter rules from flags and the selected collection
+	Exclude      []string      // Exclude filter rules from flags and the selected collection
+	DataDir      string        // Directory for local state such as the record of the last sync
+	ShowAll      bool          // status, factory-diff: list everything instead of a summary
+	Model        string        // Printer model registry key
+	Yes          bool          // Skip confirmation prompts
+	BaselineFile string        // material_database.json to use as the baseline instead of the embedded one
+	BaselineDir  string        // Directory with material_database.json and material_option.json to use as the baseline
+	SystemDir    string        // Slicer system profile directory used to resolve "inherits"; empty means detect
+	Identity     string        // Where profile IDs and names come from: notes, preset or auto
+	IDMap        string        // ID mapping file given with --id-map; empty means the one in DataDir, if any
+	IDRangeFirst int           // First ID "ids assign" may hand out
+	IDRangeLast  int           // Last ID "ids assign" may hand out
+	OnCollision  string        // What to do when a profile ID is already taken: error, warn or override
+	From3MF      string        // 3MF project whose filament presets are synced instead of --profile-path
+	FromGCode    string        // G-code file whose config block is synced instead of --profile-path
+	Nozzle       string        // Nozzle diameter fitted to the printer; empty means any the model supports
+	AllowUneval  bool          // Sync profiles whose compatible_printers_condition cannot be evaluated
+	Format       string        // lint output format: text, json or sarif
+	Mapping      string        // Field mapping file given with --mapping; empty means the one in DataDir, if any
+}
+
+// command describes a subcommand and the flags it cannot run without.
+type command struct {
+	name          string
+	description   string
+	needsProfiles bool
+	needsPrinter  bool
+}
+
+// commands lists the subcommands accepted as the first argument. The first one is the default.
+var commands = []command{
+	{name: "sync", description: "Sync custom filament profiles to the printer (default)", needsProfiles: true, needsPrinter: true},
+	{name: "status", description: "Show the sync state of each profile against the printer database", needsProfiles: true, needsPrinter: true},
+	{name: "factory-diff", description: "Compare the printer's box directory with the bundled factory snapshot", needsPrinter: true},
+	{name: "factory-reset", description: "Restore the given box files (or \"all\") from the factory snapshot", needsPrinter: true},
+	{name: "baseline", description: "\"baseline refresh\" caches the printer's stock entries; \"baseline list\" shows the cache"},
+	{name: "profiles", description: "\"profiles locate\" lists the slicer profile directories found on this computer"},
+	{name: "ids", description: "\"ids assign\" writes a free ID from --id-range into the notes of profiles that have none", needsProfiles: true},
+	{name: "lint", description: "Check the profiles for problems without contacting the printer; exits 1 on errors", needsProfiles: true},
+}
+
+// lookupCommand returns the subcommand with the given name.
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// FileConfig is the optional JSON configuration file loaded with --config.
+type FileConfig struct {
+	Collections map[string]Collection `json:"collections"`
+}
+
+// Collection is a named set of filter rules, e.g. "production" or "experimental".
+type Collection struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// DefaultIDRange is the block of IDs "ids assign" uses when --id-range is not given. It stays
+// clear of the stock Creality IDs and of the IDs derived by --identity preset.
+const DefaultIDRange = "30000-39999"
+
+// ParseIDRange parses an ID range such as "30000-39999".
+func ParseIDRange(value string) (int, int, error) {
+	firstText, lastText, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid ID range %q, expected first-last such as %s", value, DefaultIDRange)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(firstText))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ID range %q: %v", value, err)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(lastText))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ID range %q: %v", value, err)
+	}
+	if first < 0 || last > 99999 || first > last {
+		return 0, 0, fmt.Errorf("invalid ID range %q, IDs have 5 digits and the first must not exceed the last", value)
+	}
+	return first, last, nil
+}
+
+// DefaultDataDir returns the per-user directory holding the configuration file and local state.
+func DefaultDataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "filament-sync-tool")
+}
+
+// DefaultConfigPath returns the configuration file used when --config is not given.
+func DefaultConfigPath() string {
+	dir := DefaultDataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// LoadFileConfig reads a JSON configuration file.
+func LoadFileConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var fileConfig FileConfig
+	if err := json.Unmarshal(data, &fileConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &fileConfig, nil
 }
 
 // LoadConfig parses command-line arguments and returns a populated ToolConfig.
@@ -23,17 +168,51 @@ func LoadConfig() *ToolConfig {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
 	// Define command-line flags
-	profilePath := flag.String("profile-path", "", "Path to slicer filament profile directory (required)")
+	var profilePaths stringList
+	flag.Var(&profilePaths, "profile-path", "Path to slicer filament profile directory, or \"auto\" to detect it (required; repeatable, glob patterns allowed, earlier paths win on duplicate IDs)")
+	recursive := flag.Bool("recursive", false, "Also scan sub-directories of each --profile-path")
 	printerIP := flag.String("printer-ip", "", "IP address of the Creality printer (required)")
 	user := flag.String("user", "root", "Username for SSH connection to printer")
 	password := flag.String("password", "creality_2024", "Password for SSH connection to printer")
+	force := flag.Bool("force", false, "Sync even if the printer reports a running or paused print")
+	wait := flag.Duration("wait", 0, "How long to wait for a running print to finish before postponing the sync (e.g. 10m)")
+	statusURL := flag.String("status-url", "", "Base URL of the printer status API (default http://<printer-ip>:7125)")
+	configPath := flag.String("config", "", "Path to the JSON configuration file (default <user config dir>/filament-sync-tool/config.json if it exists)")
+	collection := flag.String("collection", "", "Only sync the profiles of this named collection from the configuration file")
+	var include, exclude stringList
+	flag.Var(&include, "include", "Only sync profiles matching `field:pattern` (fields: id, vendor, type, name, file; repeatable)")
+	flag.Var(&exclude, "exclude", "Skip profiles matching `field:pattern` (fields: id, vendor, type, name, file; repeatable)")
+	dataDir := flag.String("data-dir", "", "Directory for local state such as the record of the last sync (default <user config dir>/filament-sync-tool)")
+	showAll := flag.Bool("all", false, "List everything: stock entries in status, every change in factory-diff")
+	model := flag.String("model", printer.DefaultModel, fmt.Sprintf("Printer model (%s)", strings.Join(printer.ModelNames(), ", ")))
+	baselineFile := flag.String("baseline-file", "", "Use this material_database.json as the baseline instead of the cached or embedded one")
+	baselineDir := flag.String("baseline-dir", "", "Use material_database.json and material_option.json from this directory as the baseline")
+	systemDir := flag.String("system-profiles", "", "Slicer system profile directory used to resolve inherited settings (default: the system folder next to the user folder of --profile-path)")
+	identity := flag.String("identity", string(profiles.IdentityNotes), "Where profile IDs come from: notes (JSON in filament_notes), preset (filament_id and preset name) or auto (notes, else preset)")
+	idMap := flag.String("id-map", "", "JSON file pinning profile IDs by preset name or filament_id (default <data dir>/id_map.json if it exists)")
+	idRange := flag.String("id-range", DefaultIDRange, "Range of IDs \"ids assign\" hands out, as `first-last`")
+	onCollision := flag.String("on-collision", "warn", "When a profile ID is taken by a stock filament or another profile: error (stop), warn (skip the profile) or override (replace the stock entry)")
+	from3MF := flag.String("from-3mf", "", "Sync the filament presets embedded in this 3MF project instead of the ones in --profile-path")
+	fromGCode := flag.Bool("from-gcode", false, "Sync the filament presets used by the G-code file given as the last argument, as the slicer passes it to post-processing scripts, instead of the ones in --profile-path")
+	nozzle := flag.String("nozzle", "", "Nozzle diameter fitted to the printer in mm, used to check profile compatibility (default: any the model supports)")
+	allowUnevaluated := flag.Bool("allow-unevaluated-conditions", false, "Sync profiles whose compatible_printers_condition cannot be evaluated, e.g. because it uses a printer setting the tool does not know (default: skip them)")
+	format := flag.String("format", "text", "Output format of lint: text, json or sarif")
+	mapping := flag.String("mapping", "", "JSON file with rules overriding how slicer settings map to database fields (default <data dir>/mapping.json if it exists)")
+	yes := flag.Bool("yes", false, "Do not ask for confirmation before destructive commands such as factory-reset")
 
 	// Install custom usage handler with migration note BEFORE parsing
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of filament-sync-tool:\n\n")
+		fmt.Fprintf(os.Stderr, "  filament-sync-tool [command] [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.description)
+		}
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nMigration note: --userid, --flatpak, and --slicer flags have been removed.\n")
-		fmt.Fprintf(os.Stderr, "Use --profile-path with the explicit path to your filament profile directory.\n\n")
+		fmt.Fprintf(os.Stderr, "Use --profile-path with the explicit path to your filament profile directory,\n")
+		fmt.Fprintf(os.Stderr, "or --profile-path auto to use the most recently used one (see \"profiles locate\").\n\n")
 		fmt.Fprintf(os.Stderr, "Example paths:\n")
 		fmt.Fprintf(os.Stderr, "  OrcaSlicer (Linux):    ~/.config/OrcaSlicer/user/default/filament/base\n")
 		fmt.Fprintf(os.Stderr, "  OrcaSlicer (macOS):    ~/Library/Application Support/OrcaSlicer/user/default/filament/base\n")
@@ -43,42 +222,229 @@ func LoadConfig() *ToolConfig {
 		fmt.Fprintf(os.Stderr, "  Creality Print (Windows):  %%APPDATA%%\\Creality\\Creality Print\\6.0\\user\\default\\filament\\base   (replace 6.0 with your installed version)\n")
 	}
 
-	// Parse the command-line flags
-	flag.Parse()
+	// The first argument selects the subcommand when it names one; flags follow it
+	args := os.Args[1:]
+	cmd := commands[0]
+	if len(args) > 0 {
+		if named, ok := lookupCommand(args[0]); ok {
+			cmd = named
+			args = args[1:]
+		}
+	}
 
-	// --profile-path is required; exit 2 so slicers can detect misconfiguration
-	if *profilePath == "" {
-		fmt.Fprintf(os.Stderr, "Error: --profile-path is required\n\n")
-		flag.Usage()
-		os.Exit(2)
+	// Parse the command-line flags. Positional arguments (file names) may be mixed with
+	// flags, so parsing resumes after each one.
+	var positional []string
+	for {
+		if err := flag.CommandLine.Parse(args); err != nil {
+			os.Exit(2)
+		}
+		args = flag.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
 
-	// Verify the path exists
-	stat, err := os.Stat(*profilePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: profile path does not exist: %s\n", *profilePath)
-		os.Exit(1)
+	// The slicer appends the G-code path to the post-processing command
+	gcodePath := ""
+	if *fromGCode {
+		if len(positional) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --from-gcode needs the G-code file as the last argument\n\n")
+			flag.Usage()
+			os.Exit(2)
+		}
+		gcodePath = positional[len(positional)-1]
 	}
 
-	// Verify the path is a directory, not a file
-	if !stat.IsDir() {
-		fmt.Fprintf(os.Stderr, "Error: profile path is not a directory: %s\n", *profilePath)
-		os.Exit(1)
+	if *from3MF != "" || gcodePath != "" {
+		// A project or G-code file replaces the profile directories; "ids assign" only writes
+		// into preset files and lint only checks them
+		if !cmd.needsProfiles || cmd.name == "ids" || cmd.name == "lint" || len(profilePaths) > 0 || (*from3MF != "" && gcodePath != "") {
+			fmt.Fprintf(os.Stderr, "Error: --from-3mf and --from-gcode only work with sync and status, without --profile-path or each other\n\n")
+			flag.Usage()
+			os.Exit(2)
+		}
+		*from3MF = profiles.ExpandHome(*from3MF)
+		for _, source := range []string{*from3MF, gcodePath} {
+			if source == "" {
+				continue
+			}
+			if _, err := os.Stat(source); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	} else if cmd.needsProfiles {
+		// --profile-path is required; exit 2 so slicers can detect misconfiguration
+		if len(profilePaths) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --profile-path is required\n\n")
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		// Replace "auto" with the detected slicer profile directory
+		sources, err := profiles.ResolveAutoSources(profilePaths, profiles.DefaultDiscoveryRoots())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Verify every path exists and is a directory, expanding patterns
+		dirs, err := profiles.ExpandSources(sources)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		profilePaths = dirs
 	}
 
 	// Validate required --printer-ip
-	if *printerIP == "" {
+	if cmd.needsPrinter && *printerIP == "" {
 		fmt.Fprintf(os.Stderr, "Error: --printer-ip is required\n\n")
 		flag.Usage()
 		os.Exit(2)
 	}
 
-	log.Printf("Tool Config: PrinterIP=%s, User=%s, ProfilePath=%s", *printerIP, *user, *profilePath)
+	// A negative wait makes no sense; treat it as a usage error
+	if *wait < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --wait must not be negative\n\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if _, err := printer.LookupModel(*model); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *nozzle != "" {
+		if diameter, err := strconv.ParseFloat(*nozzle, 64); err != nil || diameter <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --nozzle %q, expected a diameter in mm such as 0.4\n\n", *nozzle)
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+
+	switch *format {
+	case "text", "json", "sarif":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid --format %q, expected text, json or sarif\n\n", *format)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format != "text" {
+		// Keep the report on stdout parseable
+		log.SetOutput(os.Stderr)
+	}
+
+	if _, err := profiles.ParseIdentityMode(*identity); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch *onCollision {
+	case "error", "warn", "override":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid --on-collision %q, expected error, warn or override\n\n", *onCollision)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	idRangeFirst, idRangeLast, err := ParseIDRange(*idRange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *baselineFile != "" && *baselineDir != "" {
+		fmt.Fprintf(os.Stderr, "Error: --baseline-file and --baseline-dir cannot be combined\n\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *dataDir == "" {
+		*dataDir = DefaultDataDir()
+	}
+
+	// Load the configuration file; the default location is optional, an explicit one is not
+	var fileConfig *FileConfig
+	if *configPath != "" {
+		fileConfig, err = LoadFileConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if defaultPath := DefaultConfigPath(); defaultPath != "" {
+		if _, statErr := os.Stat(defaultPath); statErr == nil {
+			fileConfig, err = LoadFileConfig(defaultPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			*configPath = defaultPath
+		}
+	}
+
+	// Merge the selected collection's rules with the ones given on the command line
+	if *collection != "" {
+		if fileConfig == nil {
+			fmt.Fprintf(os.Stderr, "Error: --collection %s requires a configuration file (--config)\n", *collection)
+			os.Exit(2)
+		}
+		rules, ok := fileConfig.Collections[*collection]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: collection %q is not defined in %s\n", *collection, *configPath)
+			os.Exit(2)
+		}
+		include = append(include, rules.Include...)
+		exclude = append(exclude, rules.Exclude...)
+	}
+
+	log.Printf("Tool Config: Command=%s, Model=%s, PrinterIP=%s, User=%s, ProfilePaths=%v, Recursive=%t, Identity=%s, Force=%t, Wait=%s", cmd.name, *model, *printerIP, *user, profilePaths, *recursive, *identity, *force, *wait)
+	if *configPath != "" {
+		log.Printf("Config file: %s", *configPath)
+	}
+	if len(include) > 0 || len(exclude) > 0 {
+		log.Printf("Profile filter: Collection=%q, Include=%v, Exclude=%v", *collection, include, exclude)
+	}
 
 	return &ToolConfig{
-		PrinterIP:   *printerIP,
-		User:        *user,
-		Password:    *password,
-		ProfilePath: *profilePath,
+		Command:      cmd.name,
+		Args:         positional,
+		PrinterIP:    *printerIP,
+		User:         *user,
+		Password:     *password,
+		ProfilePaths: profilePaths,
+		Recursive:    *recursive,
+		Force:        *force,
+		Wait:         *wait,
+		StatusURL:    *statusURL,
+		ConfigPath:   *configPath,
+		Collection:   *collection,
+		Include:      include,
+		Exclude:      exclude,
+		DataDir:      *dataDir,
+		ShowAll:      *showAll,
+		Model:        *model,
+		Yes:          *yes,
+		BaselineFile: *baselineFile,
+		BaselineDir:  *baselineDir,
+		SystemDir:    *systemDir,
+		Identity:     *identity,
+		IDMap:        *idMap,
+		IDRangeFirst: idRangeFirst,
+		IDRangeLast:  idRangeLast,
+		OnCollision:  *onCollision,
+		From3MF:      *from3MF,
+		FromGCode:    gcodePath,
+		Nozzle:       *nozzle,
+		AllowUneval:  *allowUnevaluated,
+		Format:       *format,
+		Mapping:      *mapping,
 	}
 }
@@ -1,6 +1,9 @@
 package creality
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -11,14 +14,21 @@ import (
 
 // MaterialDatabase represents the top-level structure of the Creality material_database.json.
 type MaterialDatabase struct {
-	Code   int    `json:"code"`
-	Msg    string `json:"msg"`
-	ReqID  string `json:"reqId"`
-	Result struct {
-		List    []FilamentProfileEntry `json:"list"`
-		Count   int                    `json:"count"`
-		Version string                 `json:"version"`
-	} `json:"result"`
+	Code   int            `json:"code"`
+	Msg    string         `json:"msg"`
+	ReqID  string         `json:"reqId"`
+	Result DatabaseResult `json:"result"`
+
+	unknown unknownFields // Members added by firmware updates, kept when written back
+}
+
+// DatabaseResult holds the filament entries of the material database.
+type DatabaseResult struct {
+	List    []FilamentProfileEntry `json:"list"`
+	Count   int                    `json:"count"`
+	Version string                 `json:"version"`
+
+	unknown unknownFields
 }
 
 // FilamentProfileEntry represents a single filament profile within the Creality database list.
@@ -28,124 +38,78 @@ type FilamentProfileEntry struct {
 	NozzleDiameter []string `json:"nozzleDiameter"`
 	KVParam        KVParam  `json:"kvParam"` // Key-Value Parameters specific to the printer
 	Base           BaseInfo `json:"base"`    // Base information for the filament
-}
 
-// KVParam holds the detailed technical parameters for a filament.
-type KVParam struct {
-	ActivateAirFiltration            string `json:"activate_air_filtration"`
-	ActivateChamberTempControl       string `json:"activate_chamber_temp_control"`
-	AdditionalCoolingFanSpeed        string `json:"additional_cooling_fan_speed"`
-	ChamberTemperature               string `json:"chamber_temperature"`
-	CloseFanTheFirstXLayers          string `json:"close_fan_the_first_x_layers"`
-	CompatiblePrinters               string `json:"compatible_printers"`
-	CompatiblePrintersCondition      string `json:"compatible_printers_condition"`
-	CompatiblePrints                 string `json:"compatible_prints"`
-	CompatiblePrintsCondition        string `json:"compatible_prints_condition"`
-	CompletePrintExhaustFanSpeed     string `json:"complete_print_exhaust_fan_speed"`
-	CoolCdsFanStartAtHeight          string `json:"cool_cds_fan_start_at_height"`
-	CoolPlateTemp                    string `json:"cool_plate_temp"`
-	CoolPlateTempInitialLayer        string `json:"cool_plate_temp_initial_layer"`
-	CoolSpecialCdsFanSpeed           string `json:"cool_special_cds_fan_speed"`
-	DefaultFilamentColour            string `json:"default_filament_colour"`
-	DuringPrintExhaustFanSpeed       string `json:"during_print_exhaust_fan_speed"`
-	EnableOverhangBridgeFan          string `json:"enable_overhang_bridge_fan"`
-	EnablePressureAdvance            string `json:"enable_pressure_advance"`
-	EnableSpecialAreaAdditionalCoolingFan string `json:"enable_special_area_additional_cooling_fan"`
-	EngPlateTemp                     string `json:"eng_plate_temp"`
-	EngPlateTempInitialLayer         string `json:"eng_plate_temp_initial_layer"`
-	EpoxyResinPlateTemp              string `json:"epoxy_resin_plate_temp"`
-	EpoxyResinPlateTempInitialLayer  string `json:"epoxy_resin_plate_temp_initial_layer"`
-	FanCoolingLayerTime              string `json:"fan_cooling_layer_time"`
-	FanMaxSpeed                      string `json:"fan_max_speed"`
-	FanMinSpeed                      string `json:"fan_min_speed"`
-	FilamentCoolingFinalSpeed        string `json:"filament_cooling_final_speed"`
-	FilamentCoolingInitialSpeed        string `json:"filament_cooling_initial_speed"`
-	FilamentCoolingMoves             string `json:"filament_cooling_moves"`
-	FilamentCost                     string `json:"filament_cost"`
-	FilamentDensity                  string `json:"filament_density"`
-	FilamentDeretractionSpeed        string `json:"filament_deretraction_speed"`
-	FilamentDiameter                 string `json:"filament_diameter"`
-	FilamentEndGcode                 string `json:"filament_end_gcode"`
-	FilamentFlowRatio                string `json:"filament_flow_ratio"`
-	FilamentIsSupport                string `json:"filament_is_support"`
-	FilamentLoadTime                 string `json:"filament_load_time"`
-	FilamentLoadingSpeed             string `json:"filament_loading_speed"`
-	FilamentLoadingSpeedStart        string `json:"filament_loading_speed_start"`
-	FilamentMaxVolumetricSpeed       string `json:"filament_max_volumetric_speed"`
-	FilamentMinimalPurgeOnWipeTower  string `json:"filament_minimal_purge_on_wipe_tower"`
-	FilamentMultitoolRamming         string `json:"filament_multitool_ramming"`
-	FilamentMultitoolRammingFlow     string `json:"filament_multitool_ramming_flow"`
-	FilamentMultitoolRammingVolume   string `json:"filament_multitool_ramming_volume"`
-	FilamentNotes                    string `json:"filament_notes"` // This is the stringified JSON from slicer
-	FilamentRammingParameters        string `json:"filament_ramming_parameters"`
-	FilamentRetractBeforeWipe        string `json:"filament_retract_before_wipe"`
-	FilamentRetractLiftAbove         string `json:"filament_retract_lift_above"`
-	FilamentRetractLiftBelow         string `json:"filament_retract_lift_below"`
-	FilamentRetractLiftEnforce       string `json:"filament_retract_lift_enforce"`
-	FilamentRetractRestartExtra      string `json:"filament_retract_restart_extra"`
-	FilamentRetractWhenChangingLayer string `json:"filament_retract_when_changing_layer"`
-	FilamentRetractionLength         string `json:"filament_retraction_length"`
-	FilamentRetractionMinimumTravel  string `json:"filament_retraction_minimum_travel"`
-	FilamentRetractionSpeed          string `json:"filament_retraction_speed"`
-	FilamentShrink                   string `json:"filament_shrink"`
-	FilamentShrinkageCompensationZ   string `json:"filament_shrinkage_compensation_z"`
-	FilamentSoluble                  string `json:"filament_soluble"`
-	FilamentStartGcode               string `json:"filament_start_gcode"`
-	FilamentToolchangeDelay          string `json:"filament_toolchange_delay"`
-	FilamentType                     string `json:"filament_type"` // This will be the Creality-specific type
-	FilamentUnloadTime               string `json:"filament_unload_time"`
-	FilamentUnloadingSpeed           string `json:"filament_unloading_speed"`
-	FilamentUnloadingSpeedStart      string `json:"filament_unloading_speed_start"`
-	FilamentVendor                   string `json:"filament_vendor"` // This will be the Creality-specific vendor
-	FilamentWipe                     string `json:"filament_wipe"`
-	FilamentWipeDistance             string `json:"filament_wipe_distance"`
-	FilamentZHop                     string `json:"filament_z_hop"`
-	FilamentZHopTypes                string `json:"filament_z_hop_types"`
-	FullFanSpeedLayer                string `json:"full_fan_speed_layer"`
-	HotPlateTemp                     string `json:"hot_plate_temp"`
-	HotPlateTempInitialLayer         string `json:"hot_plate_temp_initial_layer"`
-	Inherits                         string `json:"inherits"`
-	MaterialFlowDependentTemperature string `json:"material_flow_dependent_temperature"`
-	MaterialFlowTempGraph            string `json:"material_flow_temp_graph"`
-	NozzleTemperature                string `json:"nozzle_temperature"`
-	NozzleTemperatureInitialLayer    string `json:"nozzle_temperature_initial_layer"`
-	NozzleTemperatureRangeHigh       string `json:"nozzle_temperature_range_high"`
-	NozzleTemperatureRangeLow        string `json:"nozzle_temperature_range_low"`
-	OverhangFanSpeed                 string `json:"overhang_fan_speed"`
-	OverhangFanThreshold             string `json:"overhang_fan_threshold"`
-	PressureAdvance                  string `json:"pressure_advance"`
-	ReduceFanStopStartFreq           string `json:"reduce_fan_stop_start_freq"`
-	RequiredNozzleHRC                string `json:"required_nozzle_HRC"`
-	SlowDownForLayerCooling          string `json:"slow_down_for_layer_cooling"`
-	WarmupStartLayer                 string `json:"warmup_start_layer"`
-	SlowDownLayerTime                string `json:"slow_down_layer_time"`
-	SlowDownMinSpeed                 string `json:"slow_down_min_speed"`
-	SupportMaterialInterfaceFanSpeed string `json:"support_material_interface_fan_speed"`
-	TemperatureVitrification         string `json:"temperature_vitrification"`
-	TexturedPlateTemp                string `json:"textured_plate_temp"`
-	TexturedPlateTempInitialLayer    string `json:"textured_plate_temp_initial_layer"`
+	unknown unknownFields
 }
 
-// BaseInfo holds the basic identifying information for a filament.
+// BaseInfo holds the basic identifying information for a filament. Stock entries keep whole
+// numbers in costPerMeter, weightPerMeter and shrinkageRate, but the firmware's own database has
+// decimals in density, so its parser accepts them in this object. Whether the printer shows
+// fractional cost, weight and shrinkage correctly has not been checked on the firmware; a
+// mapping rule with "decimals": 0 writes whole numbers instead.
 type BaseInfo struct {
-	ID            string   `json:"id"`
-	Brand         string   `json:"brand"`
-	Name          string   `json:"name"`
-	MaterialType  string   `json:"meterialType"` // Note: "meterialType" as in source JSON
-	Colors        []string `json:"colors"`
-	Density       float64  `json:"density"`
-	Diameter      string   `json:"diameter"`
-	CostPerMeter  int      `json:"costPerMeter"`
-	WeightPerMeter int      `json:"weightPerMeter"`
-	Rank          int      `json:"rank"`
-	MinTemp       int      `json:"minTemp"`
-	MaxTemp       int      `json:"maxTemp"`
-	IsSoluble     bool     `json:"isSoluble"`
-	IsSupport     bool     `json:"isSupport"`
-	ShrinkageRate int      `json:"shrinkageRate"`
-	SofteningTemp int      `json:"softeningTemp"`
-	DryingTemp    int      `json:"dryingTemp"`
-	DryingTime    int      `json:"dryingTime"`
+	ID             string   `json:"id"`
+	Brand          string   `json:"brand"`
+	Name           string   `json:"name"`
+	MaterialType   string   `json:"meterialType"` // Note: "meterialType" as in source JSON
+	Colors         []string `json:"colors"`
+	Density        float64  `json:"density"`
+	Diameter       string   `json:"diameter"`
+	CostPerMeter   float64  `json:"costPerMeter"`   // In the currency of filament_cost
+	WeightPerMeter float64  `json:"weightPerMeter"` // Grams
+	Rank           int      `json:"rank"`
+	MinTemp        int      `json:"minTemp"`
+	MaxTemp        int      `json:"maxTemp"`
+	IsSoluble      bool     `json:"isSoluble"`
+	IsSupport      bool     `json:"isSupport"`
+	ShrinkageRate  float64  `json:"shrinkageRate"`
+	SofteningTemp  int      `json:"softeningTemp"`
+	DryingTemp     int      `json:"dryingTemp"`
+	DryingTime     int      `json:"dryingTime"`
+
+	unknown unknownFields
+}
+
+// The database types keep the members they do not declare, see unknownFields.
+
+func (db *MaterialDatabase) UnmarshalJSON(data []byte) error {
+	type plain MaterialDatabase
+	return readObject(data, (*plain)(db), &db.unknown)
+}
+
+func (db MaterialDatabase) MarshalJSON() ([]byte, error) {
+	type plain MaterialDatabase
+	return writeObject(plain(db), db.unknown)
+}
+
+func (r *DatabaseResult) UnmarshalJSON(data []byte) error {
+	type plain DatabaseResult
+	return readObject(data, (*plain)(r), &r.unknown)
+}
+
+func (r DatabaseResult) MarshalJSON() ([]byte, error) {
+	type plain DatabaseResult
+	return writeObject(plain(r), r.unknown)
+}
+
+func (e *FilamentProfileEntry) UnmarshalJSON(data []byte) error {
+	type plain FilamentProfileEntry
+	return readObject(data, (*plain)(e), &e.unknown)
+}
+
+func (e FilamentProfileEntry) MarshalJSON() ([]byte, error) {
+	type plain FilamentProfileEntry
+	return writeObject(plain(e), e.unknown)
+}
+
+func (b *BaseInfo) UnmarshalJSON(data []byte) error {
+	type plain BaseInfo
+	return readObject(data, (*plain)(b), &b.unknown)
+}
+
+func (b BaseInfo) MarshalJSON() ([]byte, error) {
+	type plain BaseInfo
+	return writeObject(plain(b), b.unknown)
 }
 
 // MaterialOptions represents the structure of material_option.json.
@@ -170,11 +134,20 @@ func LoadDefaultOptionsFromBytes(data []byte) (MaterialOptions, error) {
 	return options, nil
 }
 
-// ConvertToCrealityFormat converts a normalized slicer profile into a CrealityFilamentData structure.
-func ConvertToCrealityFormat(slicerProfileData map[string]string, notes *profiles.FilamentNotes) (*FilamentProfileEntry, error) {
+// ConvertToCrealityFormat converts a normalized slicer profile into a CrealityFilamentData
+// structure, following the rules of mapping. A nil mapping uses the embedded one. With a
+// template, see FindTemplate, the entry starts as a copy of its settings and only what the
+// profile sets is overlaid; without one, unset parameters get the mapping's defaults.
+func ConvertToCrealityFormat(slicerProfileData map[string]string, notes *profiles.FilamentNotes, mapping *Mapping, template *FilamentProfileEntry) (*FilamentProfileEntry, error) {
 	if notes == nil {
 		return nil, fmt.Errorf("filament notes must not be nil")
 	}
+	if mapping == nil {
+		var err error
+		if mapping, err = DefaultMapping(); err != nil {
+			return nil, err
+		}
+	}
 	// Marshal the original filamentNotes struct to a JSON string for storage in kvParam.filament_notes.
 	// The reference format stores this as a plain JSON string (not double-encoded).
 	notesBytes, err := json.Marshal(notes)
@@ -183,180 +156,43 @@ func ConvertToCrealityFormat(slicerProfileData map[string]string, notes *profile
 	}
 	notesJSON := string(notesBytes)
 
-	newEntry := &FilamentProfileEntry{
-		EngineVersion:  "3.0.0", // Creality database format version, not the slicer profile version
-		PrinterIntName: "F008",          // Hardcoded from original JS
-		NozzleDiameter: []string{"0.4"}, // Hardcoded from original JS
-		KVParam:        KVParam{},
-		Base: BaseInfo{
-			ID:           notes.ID,
-			Brand:        notes.Vendor,
-			Name:         notes.Name,
-			MaterialType: notes.Type,
-			Colors:       []string{"#ffffff"}, // Default color, can be enhanced
-			Diameter:     slicerProfileData["filament_diameter"],
-		},
+	// Rules read the profile's settings, plus the sync metadata as filament_notes and the
+	// optional note fields the mapping uses, when they are set
+	settings := make(map[string]string, len(slicerProfileData)+4)
+	for key, value := range slicerProfileData {
+		settings[key] = value
 	}
-
-	assignKVParam := func(key string) string {
-		if val, ok := slicerProfileData[key]; ok {
-			return val
-		}
-		return ""
+	settings["filament_notes"] = notesJSON
+	if notes.DryingTemp > 0 {
+		settings["filament_notes.dryingTemp"] = strconv.Itoa(notes.DryingTemp)
 	}
-
-	newEntry.KVParam = KVParam{
-		ActivateAirFiltration:            assignKVParam("activate_air_filtration"),
-		ActivateChamberTempControl:       assignKVParam("activate_chamber_temp_control"),
-		AdditionalCoolingFanSpeed:        assignKVParam("additional_cooling_fan_speed"),
-		ChamberTemperature:               assignKVParam("chamber_temperature"),
-		CloseFanTheFirstXLayers:          assignKVParam("close_fan_the_first_x_layers"),
-		CompatiblePrinters:               assignKVParam("compatible_printers"),
-		CompatiblePrintersCondition:      assignKVParam("compatible_printers_condition"),
-		CompatiblePrints:                 assignKVParam("compatible_prints"),
-		CompatiblePrintsCondition:        assignKVParam("compatible_prints_condition"),
-		CompletePrintExhaustFanSpeed:     assignKVParam("complete_print_exhaust_fan_speed"),
-		CoolCdsFanStartAtHeight:          assignKVParam("cool_cds_fan_start_at_height"),
-		CoolPlateTemp:                    assignKVParam("cool_plate_temp"),
-		CoolPlateTempInitialLayer:        assignKVParam("cool_plate_temp_initial_layer"),
-		CoolSpecialCdsFanSpeed:           assignKVParam("cool_special_cds_fan_speed"),
-		DefaultFilamentColour:            assignKVParam("default_filament_colour"),
-		DuringPrintExhaustFanSpeed:       assignKVParam("during_print_exhaust_fan_speed"),
-		EnableOverhangBridgeFan:          assignKVParam("enable_overhang_bridge_fan"),
-		EnablePressureAdvance:            assignKVParam("enable_pressure_advance"),
-		EnableSpecialAreaAdditionalCoolingFan: assignKVParam("enable_special_area_additional_cooling_fan"),
-		EngPlateTemp:                     assignKVParam("eng_plate_temp"),
-		EngPlateTempInitialLayer:         assignKVParam("eng_plate_temp_initial_layer"),
-		EpoxyResinPlateTemp:              assignKVParam("epoxy_resin_plate_temp"),
-		EpoxyResinPlateTempInitialLayer:  assignKVParam("epoxy_resin_plate_temp_initial_layer"),
-		FanCoolingLayerTime:              assignKVParam("fan_cooling_layer_time"),
-		FanMaxSpeed:                      assignKVParam("fan_max_speed"),
-		FanMinSpeed:                      assignKVParam("fan_min_speed"),
-		FilamentCoolingFinalSpeed:        assignKVParam("filament_cooling_final_speed"),
-		FilamentCoolingInitialSpeed:      assignKVParam("filament_cooling_initial_speed"),
-		FilamentCoolingMoves:             assignKVParam("filament_cooling_moves"),
-		FilamentCost:                     assignKVParam("filament_cost"),
-		FilamentDensity:                  assignKVParam("filament_density"),
-		FilamentDeretractionSpeed:        assignKVParam("filament_deretraction_speed"),
-		FilamentDiameter:                 assignKVParam("filament_diameter"),
-		FilamentEndGcode:                 assignKVParam("filament_end_gcode"),
-		FilamentFlowRatio:                assignKVParam("filament_flow_ratio"),
-		FilamentIsSupport:                assignKVParam("filament_is_support"),
-		FilamentLoadTime:                 assignKVParam("filament_load_time"),
-		FilamentLoadingSpeed:             assignKVParam("filament_loading_speed"),
-		FilamentLoadingSpeedStart:        assignKVParam("filament_loading_speed_start"),
-		FilamentMaxVolumetricSpeed:       assignKVParam("filament_max_volumetric_speed"),
-		FilamentMinimalPurgeOnWipeTower:  assignKVParam("filament_minimal_purge_on_wipe_tower"),
-		FilamentMultitoolRamming:         assignKVParam("filament_multitool_ramming"),
-		FilamentMultitoolRammingFlow:     assignKVParam("filament_multitool_ramming_flow"),
-		FilamentMultitoolRammingVolume:   assignKVParam("filament_multitool_ramming_volume"),
-		FilamentNotes:                    notesJSON,
-		FilamentRammingParameters:        assignKVParam("filament_ramming_parameters"),
-		FilamentRetractBeforeWipe:        assignKVParam("filament_retract_before_wipe"),
-		FilamentRetractLiftAbove:         assignKVParam("filament_retract_lift_above"),
-		FilamentRetractLiftBelow:         assignKVParam("filament_retract_lift_below"),
-		FilamentRetractLiftEnforce:       assignKVParam("filament_retract_lift_enforce"),
-		FilamentRetractRestartExtra:      assignKVParam("filament_retract_restart_extra"),
-		FilamentRetractWhenChangingLayer: assignKVParam("filament_retract_when_changing_layer"),
-		FilamentRetractionLength:         assignKVParam("filament_retraction_length"),
-		FilamentRetractionMinimumTravel:  assignKVParam("filament_retraction_minimum_travel"),
-		FilamentRetractionSpeed:          assignKVParam("filament_retraction_speed"),
-		FilamentShrink:                   assignKVParam("filament_shrink"),
-		FilamentShrinkageCompensationZ:   assignKVParam("filament_shrinkage_compensation_z"),
-		FilamentSoluble:                  assignKVParam("filament_soluble"),
-		FilamentStartGcode:               assignKVParam("filament_start_gcode"),
-		FilamentToolchangeDelay:          assignKVParam("filament_toolchange_delay"),
-		FilamentType:                     assignKVParam("filament_type"),
-		FilamentUnloadTime:               assignKVParam("filament_unload_time"),
-		FilamentUnloadingSpeed:           assignKVParam("filament_unloading_speed"),
-		FilamentUnloadingSpeedStart:      assignKVParam("filament_unloading_speed_start"),
-		FilamentVendor:                   assignKVParam("filament_vendor"),
-		FilamentWipe:                     assignKVParam("filament_wipe"),
-		FilamentWipeDistance:             assignKVParam("filament_wipe_distance"),
-		FilamentZHop:                     assignKVParam("filament_z_hop"),
-		FilamentZHopTypes:                assignKVParam("filament_z_hop_types"),
-		FullFanSpeedLayer:                assignKVParam("full_fan_speed_layer"),
-		HotPlateTemp:                     assignKVParam("hot_plate_temp"),
-		HotPlateTempInitialLayer:         assignKVParam("hot_plate_temp_initial_layer"),
-		Inherits:                         assignKVParam("inherits"),
-		MaterialFlowDependentTemperature: assignKVParam("material_flow_dependent_temperature"),
-		MaterialFlowTempGraph:            assignKVParam("material_flow_temp_graph"),
-		NozzleTemperature:                assignKVParam("nozzle_temperature"),
-		NozzleTemperatureInitialLayer:    assignKVParam("nozzle_temperature_initial_layer"),
-		NozzleTemperatureRangeHigh:       assignKVParam("nozzle_temperature_range_high"),
-		NozzleTemperatureRangeLow:        assignKVParam("nozzle_temperature_range_low"),
-		OverhangFanSpeed:                 assignKVParam("overhang_fan_speed"),
-		OverhangFanThreshold:             assignKVParam("overhang_fan_threshold"),
-		PressureAdvance:                  assignKVParam("pressure_advance"),
-		ReduceFanStopStartFreq:           assignKVParam("reduce_fan_stop_start_freq"),
-		RequiredNozzleHRC:                assignKVParam("required_nozzle_HRC"),
-		SlowDownForLayerCooling:          assignKVParam("slow_down_for_layer_cooling"),
-		WarmupStartLayer:                 assignKVParam("warmup_start_layer"),
-		SlowDownLayerTime:                assignKVParam("slow_down_layer_time"),
-		SlowDownMinSpeed:                 assignKVParam("slow_down_min_speed"),
-		SupportMaterialInterfaceFanSpeed: assignKVParam("support_material_interface_fan_speed"),
-		TemperatureVitrification:         assignKVParam("temperature_vitrification"),
-		TexturedPlateTemp:                assignKVParam("textured_plate_temp"),
-		TexturedPlateTempInitialLayer:    assignKVParam("textured_plate_temp_initial_layer"),
-	}
-
-	if notes.Type != "" {
-		newEntry.KVParam.FilamentType = notes.Type
+	if notes.DryingTime > 0 {
+		settings["filament_notes.dryingTime"] = strconv.Itoa(notes.DryingTime)
 	}
-	if notes.Vendor != "" {
-		newEntry.KVParam.FilamentVendor = notes.Vendor
+	if notes.WeightPerMeter > 0 {
+		settings["filament_notes.weightPerMeter"] = strconv.FormatFloat(notes.WeightPerMeter, 'f', -1, 64)
 	}
 
-	newEntry.Base.IsSoluble = assignKVParam("filament_soluble") == "1"
-	newEntry.Base.IsSupport = assignKVParam("filament_is_support") == "1"
-
-	if density, err := strconv.ParseFloat(assignKVParam("filament_density"), 64); err == nil {
-		newEntry.Base.Density = density
-	}
-	if cost, err := strconv.Atoi(assignKVParam("filament_cost")); err == nil {
-		newEntry.Base.CostPerMeter = cost
+	newEntry := &FilamentProfileEntry{}
+	if template != nil {
+		newEntry = fromTemplate(template)
 	}
+	newEntry.NozzleDiameter = NozzleDiameters(slicerProfileData, notes) // From notes or compatible_printers, 0.4 by default
+	mapping.apply(newEntry, settings)
+	// The metadata marks the entry as synced by this tool, whatever the mapping says
+	newEntry.KVParam.Set(ParamFilamentNotes, notesJSON)
 
-	if minTemp, err := strconv.Atoi(assignKVParam("nozzle_temperature_range_low")); err == nil {
-		newEntry.Base.MinTemp = minTemp
-	}
-	if maxTemp, err := strconv.Atoi(assignKVParam("nozzle_temperature_range_high")); err == nil {
-		newEntry.Base.MaxTemp = maxTemp
-	}
-	if shrinkageRate, err := strconv.Atoi(strings.TrimSuffix(assignKVParam("filament_shrink"), "%")); err == nil {
-		newEntry.Base.ShrinkageRate = shrinkageRate
-	}
-	if softeningTemp, err := strconv.Atoi(assignKVParam("temperature_vitrification")); err == nil {
-		newEntry.Base.SofteningTemp = softeningTemp
+	// Optional note fields describe what the slicer profile cannot and take precedence
+	if len(notes.Colors) > 0 {
+		newEntry.Base.Colors = append([]string(nil), notes.Colors...)
 	}
-	if dryingTemp, err := strconv.Atoi(assignKVParam("hot_plate_temp")); err == nil {
-		newEntry.Base.DryingTemp = dryingTemp
-	}
-	if dryingTime, err := strconv.Atoi(assignKVParam("slow_down_layer_time")); err == nil {
-		newEntry.Base.DryingTime = dryingTime
+	if notes.Rank > 0 {
+		newEntry.Base.Rank = notes.Rank
 	}
 
 	return newEntry, nil
 }
 
-// AddProfileToDatabase adds a new filament profile entry to the MaterialDatabase.
-// It replaces an existing entry if one with the same ID is found, otherwise appends.
-func AddProfileToDatabase(db *MaterialDatabase, newProfile *FilamentProfileEntry, version string) {
-	found := false
-	for i, entry := range db.Result.List {
-		if entry.Base.ID == newProfile.Base.ID {
-			db.Result.List[i] = *newProfile // Replace existing
-			found = true
-			break
-		}
-	}
-	if !found {
-		db.Result.List = append(db.Result.List, *newProfile) // Add new
-		db.Result.Count = len(db.Result.List) // Update count
-	}
-	db.Result.Version = version // Update the database version
-}
-
 // UpdateOptions updates the MaterialOptions with a new filament entry.
 // It handles adding new vendors, new filament types for existing vendors,
 // and appending new names to existing vendor/type combinations.
@@ -398,12 +234,17 @@ func UpdateOptions(options MaterialOptions, notes *profiles.FilamentNotes) {
 }
 
 // MarshalDatabase converts a MaterialDatabase struct to its JSON byte representation.
+// Characters such as < and > in G-code are written as they are, like the printer does, so
+// values read from its database are written back unchanged.
 func MarshalDatabase(db *MaterialDatabase) ([]byte, error) {
-	data, err := json.MarshalIndent(db, "", "\t")
-	if err != nil {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	if err := encoder.Encode(db); err != nil {
 		return nil, fmt.Errorf("failed to marshal material database to bytes: %w", err)
 	}
-	return data, nil
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 // MarshalOptions converts MaterialOptions to its JSON byte representation.
@@ -415,3 +256,116 @@ func MarshalOptions(options MaterialOptions) ([]byte, error) {
 	return data, nil
 }
 
+// SyncStatus describes how a local profile relates to the printer's copy of the same entry.
+type SyncStatus string
+
+const (
+	StatusInSync         SyncStatus = "in sync"
+	StatusChangedLocally SyncStatus = "changed locally"
+	StatusChangedPrinter SyncStatus = "changed on printer"
+	StatusDiverged       SyncStatus = "changed on both"
+	StatusLocalOnly      SyncStatus = "local only"
+	StatusOrphan         SyncStatus = "orphan"
+	StatusStock          SyncStatus = "stock"
+	StatusCollision      SyncStatus = "ID collision"
+)
+
+// EntryFingerprint returns a stable hash of an entry, used to detect changes between syncs.
+func EntryFingerprint(entry *FilamentProfileEntry) (string, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal entry %s: %w", entry.Base.ID, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CompareFingerprints classifies an entry present both locally and on the printer.
+// lastSynced is the fingerprint recorded at the last sync, empty if unknown; without it
+// a difference is attributed to the local side, since the next sync pushes the local copy.
+func CompareFingerprints(local, onPrinter, lastSynced string) SyncStatus {
+	switch {
+	case local == onPrinter:
+		return StatusInSync
+	case lastSynced == "" || onPrinter == lastSynced:
+		return StatusChangedLocally
+	case local == lastSynced:
+		return StatusChangedPrinter
+	default:
+		return StatusDiverged
+	}
+}
+
+// FindEntry returns the entry with the given Base.ID, or nil.
+func FindEntry(db *MaterialDatabase, id string) *FilamentProfileEntry {
+	for i := range db.Result.List {
+		if db.Result.List[i].Base.ID == id {
+			return &db.Result.List[i]
+		}
+	}
+	return nil
+}
+
+// FindStockEntry returns the entry with the given Base.ID that was not written by this tool,
+// or nil. A custom profile with that ID would replace a stock filament.
+func FindStockEntry(db *MaterialDatabase, id string) *FilamentProfileEntry {
+	for i := range db.Result.List {
+		if db.Result.List[i].Base.ID == id && !HasSyncNotes(&db.Result.List[i]) {
+			return &db.Result.List[i]
+		}
+	}
+	return nil
+}
+
+// HasSyncNotes reports whether an entry's filament_notes carry the sync metadata written by this tool.
+// Stock entries ship with empty notes.
+func HasSyncNotes(entry *FilamentProfileEntry) bool {
+	var notes profiles.FilamentNotes
+	if err := json.Unmarshal([]byte(entry.KVParam.Get(ParamFilamentNotes)), &notes); err != nil {
+		return false
+	}
+	return notes.ID != ""
+}
+
+// StockDatabase returns a copy of db without the entries synced by this tool.
+func StockDatabase(db *MaterialDatabase) *MaterialDatabase {
+	stock := *db
+	stock.Result.List = nil
+	for _, entry := range db.Result.List {
+		if !HasSyncNotes(&entry) {
+			stock.Result.List = append(stock.Result.List, entry)
+		}
+	}
+	stock.Result.Count = len(stock.Result.List)
+	return &stock
+}
+
+// StockOptions returns a copy of options without the names of the entries synced by this tool.
+func StockOptions(options MaterialOptions, db *MaterialDatabase) MaterialOptions {
+	custom := make(map[string]bool)
+	for _, entry := range db.Result.List {
+		if HasSyncNotes(&entry) {
+			custom[entry.Base.Brand+"\x00"+entry.Base.MaterialType+"\x00"+entry.Base.Name] = true
+		}
+	}
+
+	stock := make(MaterialOptions)
+	for vendor, types := range options {
+		for filamentType, names := range types {
+			var kept []string
+			for _, name := range strings.Split(names, "\n") {
+				if !custom[vendor+"\x00"+filamentType+"\x00"+name] {
+					kept = append(kept, name)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			if _, ok := stock[vendor]; !ok {
+				stock[vendor] = make(map[string]string)
+			}
+			stock[vendor][filamentType] = strings.Join(kept, "\n")
+		}
+	}
+	return stock
+}
@@ -0,0 +1,123 @@
+package creality
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// firmwareFields inserts members the tool does not declare at every level of the database,
+// before a member it does, as a firmware update might add them.
+var firmwareFields = []struct{ before, insert string }{
+	{`"msg":`, `"serverTime":1739347111,`},
+	{`"count":`, `"pageSize":{"page":1,"size":100},`},
+	{`"kvParam":`, `"materialDeviceId":"cx-01",`},
+	{`"cool_plate_temp":`, `"enable_chamber_preheat":"1",`},
+	{`"rank":`, `"spoolWeight":1000,"printableTag":"<PLA>",`},
+}
+
+func TestDatabaseRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../data/material_database.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, field := range firmwareFields {
+		if !strings.Contains(text, field.before) {
+			t.Fatalf("test database has no %s member", field.before)
+		}
+		text = strings.Replace(text, field.before, field.insert+field.before, 1)
+	}
+	// MarshalDatabase writes tab-indented JSON; the stock file is indented with spaces
+	var want bytes.Buffer
+	if err := json.Indent(&want, []byte(text), "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := LoadDefaultDatabaseFromBytes(want.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := MarshalDatabase(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("round-trip changed the database:\n%s", firstDifference(got, want.Bytes()))
+	}
+	for _, field := range firmwareFields {
+		if !bytes.Contains(got, []byte(strings.SplitN(field.insert, ":", 2)[0])) {
+			t.Errorf("round-trip dropped %s", field.insert)
+		}
+	}
+}
+
+func TestChangedEntryKeepsUnknownFields(t *testing.T) {
+	db, err := LoadDefaultDatabaseFromBytes([]byte(`{"code":0,"result":{"list":[` +
+		`{"engineVersion":"3.0.0","extra":[1,2],"kvParam":{"nozzle_temperature":"220","new_key":"x"},"base":{"id":"01001","hue":12,"rank":1}}` +
+		`],"count":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &db.Result.List[0]
+	entry.Base.Rank = 2
+	entry.KVParam.Set(ParamNozzleTemperature, "230")
+	db.Result.Version = "2"
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`{"engineVersion":"3.0.0","extra":[1,2],"kvParam":{"nozzle_temperature":"230","new_key":"x"},"base":{"id":"01001","hue":12,"rank":2,`,
+		`"count":1,"version":"2"}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("marshalled database %s does not contain %s", data, want)
+		}
+	}
+}
+
+func TestKVParamTypedAccess(t *testing.T) {
+	var kv KVParam
+	if err := json.Unmarshal([]byte(`{"nozzle_temperature":"219.6","filament_shrink":"99.5%","filament_soluble":"1","filament_type":"PLA","chamber_temperature":35}`), &kv); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, ok := kv.Int(ParamNozzleTemperature); !ok || value != 220 {
+		t.Errorf("Int(nozzle_temperature) = %d, %t, want 220", value, ok)
+	}
+	if value, ok := kv.Float(ParamFilamentShrink); !ok || value != 99.5 {
+		t.Errorf("Float(filament_shrink) = %v, %t, want 99.5", value, ok)
+	}
+	if value, ok := kv.Bool(ParamFilamentSoluble); !ok || !value {
+		t.Errorf("Bool(filament_soluble) = %t, %t, want true", value, ok)
+	}
+	if value, ok := kv.Int(ParamChamberTemperature); !ok || value != 35 {
+		t.Errorf("Int(chamber_temperature) = %d, %t, want 35 from a JSON number", value, ok)
+	}
+	if _, ok := kv.Float(ParamFilamentType); ok {
+		t.Error("Float(filament_type) read text as a number")
+	}
+	if _, ok := kv.Int(ParamPressureAdvance); ok || kv.Has(ParamPressureAdvance) {
+		t.Error("a missing parameter reads as set")
+	}
+
+	kv.Set(ParamPressureAdvance, "0.04")
+	keys := kv.Keys()
+	if keys[len(keys)-1] != ParamPressureAdvance {
+		t.Errorf("Keys() = %v, want pressure_advance last", keys)
+	}
+}
+
+// firstDifference shows where two byte slices start to differ.
+func firstDifference(got, want []byte) string {
+	i := 0
+	for i < len(got) && i < len(want) && got[i] == want[i] {
+		i++
+	}
+	start := max(i-80, 0)
+	return "got:  ..." + string(got[start:min(i+80, len(got))]) + "\nwant: ..." + string(want[start:min(i+80, len(want))])
+}
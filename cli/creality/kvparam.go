@@ -0,0 +1,242 @@
+package creality
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParamKey names a kvParam parameter. The constants are the parameters the tool knows; other
+// names, such as those a mapping file adds, are converted explicitly.
+type ParamKey string
+
+const (
+	ParamActivateAirFiltration                 ParamKey = "activate_air_filtration"
+	ParamActivateChamberTempControl            ParamKey = "activate_chamber_temp_control"
+	ParamAdditionalCoolingFanSpeed             ParamKey = "additional_cooling_fan_speed"
+	ParamChamberTemperature                    ParamKey = "chamber_temperature"
+	ParamCloseFanTheFirstXLayers               ParamKey = "close_fan_the_first_x_layers"
+	ParamCompatiblePrinters                    ParamKey = "compatible_printers"
+	ParamCompatiblePrintersCondition           ParamKey = "compatible_printers_condition"
+	ParamCompatiblePrints                      ParamKey = "compatible_prints"
+	ParamCompatiblePrintsCondition             ParamKey = "compatible_prints_condition"
+	ParamCompletePrintExhaustFanSpeed          ParamKey = "complete_print_exhaust_fan_speed"
+	ParamCoolCdsFanStartAtHeight               ParamKey = "cool_cds_fan_start_at_height"
+	ParamCoolPlateTemp                         ParamKey = "cool_plate_temp"
+	ParamCoolPlateTempInitialLayer             ParamKey = "cool_plate_temp_initial_layer"
+	ParamCoolSpecialCdsFanSpeed                ParamKey = "cool_special_cds_fan_speed"
+	ParamDefaultFilamentColour                 ParamKey = "default_filament_colour"
+	ParamDuringPrintExhaustFanSpeed            ParamKey = "during_print_exhaust_fan_speed"
+	ParamEnableOverhangBridgeFan               ParamKey = "enable_overhang_bridge_fan"
+	ParamEnablePressureAdvance                 ParamKey = "enable_pressure_advance"
+	ParamEnableSpecialAreaAdditionalCoolingFan ParamKey = "enable_special_area_additional_cooling_fan"
+	ParamEngPlateTemp                          ParamKey = "eng_plate_temp"
+	ParamEngPlateTempInitialLayer              ParamKey = "eng_plate_temp_initial_layer"
+	ParamEpoxyResinPlateTemp                   ParamKey = "epoxy_resin_plate_temp"
+	ParamEpoxyResinPlateTempInitialLayer       ParamKey = "epoxy_resin_plate_temp_initial_layer"
+	ParamFanCoolingLayerTime                   ParamKey = "fan_cooling_layer_time"
+	ParamFanMaxSpeed                           ParamKey = "fan_max_speed"
+	ParamFanMinSpeed                           ParamKey = "fan_min_speed"
+	ParamFilamentCoolingFinalSpeed             ParamKey = "filament_cooling_final_speed"
+	ParamFilamentCoolingInitialSpeed           ParamKey = "filament_cooling_initial_speed"
+	ParamFilamentCoolingMoves                  ParamKey = "filament_cooling_moves"
+	ParamFilamentCost                          ParamKey = "filament_cost"
+	ParamFilamentDensity                       ParamKey = "filament_density"
+	ParamFilamentDeretractionSpeed             ParamKey = "filament_deretraction_speed"
+	ParamFilamentDiameter                      ParamKey = "filament_diameter"
+	ParamFilamentEndGcode                      ParamKey = "filament_end_gcode"
+	ParamFilamentFlowRatio                     ParamKey = "filament_flow_ratio"
+	ParamFilamentIsSupport                     ParamKey = "filament_is_support"
+	ParamFilamentLoadTime                      ParamKey = "filament_load_time"
+	ParamFilamentLoadingSpeed                  ParamKey = "filament_loading_speed"
+	ParamFilamentLoadingSpeedStart             ParamKey = "filament_loading_speed_start"
+	ParamFilamentMaxVolumetricSpeed            ParamKey = "filament_max_volumetric_speed"
+	ParamFilamentMinimalPurgeOnWipeTower       ParamKey = "filament_minimal_purge_on_wipe_tower"
+	ParamFilamentMultitoolRamming              ParamKey = "filament_multitool_ramming"
+	ParamFilamentMultitoolRammingFlow          ParamKey = "filament_multitool_ramming_flow"
+	ParamFilamentMultitoolRammingVolume        ParamKey = "filament_multitool_ramming_volume"
+	ParamFilamentNotes                         ParamKey = "filament_notes" // The sync metadata, stored as JSON text
+	ParamFilamentRammingParameters             ParamKey = "filament_ramming_parameters"
+	ParamFilamentRetractBeforeWipe             ParamKey = "filament_retract_before_wipe"
+	ParamFilamentRetractLiftAbove              ParamKey = "filament_retract_lift_above"
+	ParamFilamentRetractLiftBelow              ParamKey = "filament_retract_lift_below"
+	ParamFilamentRetractLiftEnforce            ParamKey = "filament_retract_lift_enforce"
+	ParamFilamentRetractRestartExtra           ParamKey = "filament_retract_restart_extra"
+	ParamFilamentRetractWhenChangingLayer      ParamKey = "filament_retract_when_changing_layer"
+	ParamFilamentRetractionLength              ParamKey = "filament_retraction_length"
+	ParamFilamentRetractionMinimumTravel       ParamKey = "filament_retraction_minimum_travel"
+	ParamFilamentRetractionSpeed               ParamKey = "filament_retraction_speed"
+	ParamFilamentShrink                        ParamKey = "filament_shrink"
+	ParamFilamentShrinkageCompensationZ        ParamKey = "filament_shrinkage_compensation_z"
+	ParamFilamentSoluble                       ParamKey = "filament_soluble"
+	ParamFilamentStartGcode                    ParamKey = "filament_start_gcode"
+	ParamFilamentToolchangeDelay               ParamKey = "filament_toolchange_delay"
+	ParamFilamentType                          ParamKey = "filament_type"
+	ParamFilamentUnloadTime                    ParamKey = "filament_unload_time"
+	ParamFilamentUnloadingSpeed                ParamKey = "filament_unloading_speed"
+	ParamFilamentUnloadingSpeedStart           ParamKey = "filament_unloading_speed_start"
+	ParamFilamentVendor                        ParamKey = "filament_vendor"
+	ParamFilamentWipe                          ParamKey = "filament_wipe"
+	ParamFilamentWipeDistance                  ParamKey = "filament_wipe_distance"
+	ParamFilamentZHop                          ParamKey = "filament_z_hop"
+	ParamFilamentZHopTypes                     ParamKey = "filament_z_hop_types"
+	ParamFullFanSpeedLayer                     ParamKey = "full_fan_speed_layer"
+	ParamHotPlateTemp                          ParamKey = "hot_plate_temp"
+	ParamHotPlateTempInitialLayer              ParamKey = "hot_plate_temp_initial_layer"
+	ParamInherits                              ParamKey = "inherits"
+	ParamMaterialFlowDependentTemperature      ParamKey = "material_flow_dependent_temperature"
+	ParamMaterialFlowTempGraph                 ParamKey = "material_flow_temp_graph"
+	ParamNozzleTemperature                     ParamKey = "nozzle_temperature"
+	ParamNozzleTemperatureInitialLayer         ParamKey = "nozzle_temperature_initial_layer"
+	ParamNozzleTemperatureRangeHigh            ParamKey = "nozzle_temperature_range_high"
+	ParamNozzleTemperatureRangeLow             ParamKey = "nozzle_temperature_range_low"
+	ParamOverhangFanSpeed                      ParamKey = "overhang_fan_speed"
+	ParamOverhangFanThreshold                  ParamKey = "overhang_fan_threshold"
+	ParamPressureAdvance                       ParamKey = "pressure_advance"
+	ParamReduceFanStopStartFreq                ParamKey = "reduce_fan_stop_start_freq"
+	ParamRequiredNozzleHRC                     ParamKey = "required_nozzle_HRC"
+	ParamSlowDownForLayerCooling               ParamKey = "slow_down_for_layer_cooling"
+	ParamWarmupStartLayer                      ParamKey = "warmup_start_layer"
+	ParamSlowDownLayerTime                     ParamKey = "slow_down_layer_time"
+	ParamSlowDownMinSpeed                      ParamKey = "slow_down_min_speed"
+	ParamSupportMaterialInterfaceFanSpeed      ParamKey = "support_material_interface_fan_speed"
+	ParamTemperatureVitrification              ParamKey = "temperature_vitrification"
+	ParamTexturedPlateTemp                     ParamKey = "textured_plate_temp"
+	ParamTexturedPlateTempInitialLayer         ParamKey = "textured_plate_temp_initial_layer"
+)
+
+// KVParam holds the detailed technical parameters for a filament, keyed by their database
+// name. Keys keep the order they were read or set in, and values are kept as raw JSON, so keys
+// the tool does not know, such as those added by a firmware update, are written back exactly
+// as they were read. The zero value is an empty set of parameters.
+type KVParam struct {
+	keys   []ParamKey
+	values map[ParamKey]json.RawMessage
+}
+
+// Get returns the value of a parameter. The printer stores every value as a string; any other
+// JSON value is returned as its JSON text. A missing parameter reads as "".
+func (kv KVParam) Get(key ParamKey) string {
+	raw, ok := kv.values[key]
+	if !ok {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+// Int returns the value of a parameter as a whole number, rounding decimals, and whether it is
+// set to a number.
+func (kv KVParam) Int(key ParamKey) (int, bool) {
+	value, ok := kv.Float(key)
+	if !ok {
+		return 0, false
+	}
+	return int(math.Round(value)), true
+}
+
+// Float returns the value of a parameter as a number, and whether it is set to one. A
+// percentage such as "99.5%" reads as 99.5.
+func (kv KVParam) Float(key ParamKey) (float64, bool) {
+	return parseNumber(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(kv.Get(key)), "%")))
+}
+
+// Bool returns the value of a 0/1 flag parameter, and whether it is set to one.
+func (kv KVParam) Bool(key ParamKey) (bool, bool) {
+	value, err := strconv.ParseBool(strings.TrimSpace(kv.Get(key)))
+	return value, err == nil
+}
+
+// Has reports whether the parameter is present.
+func (kv KVParam) Has(key ParamKey) bool {
+	_, ok := kv.values[key]
+	return ok
+}
+
+// Set sets a parameter to a string value. A new key goes after the existing ones; an existing
+// key keeps its place.
+func (kv *KVParam) Set(key ParamKey, value string) {
+	raw, _ := json.Marshal(value) // Marshalling a string cannot fail
+	kv.setRaw(key, raw)
+}
+
+// setRaw sets a parameter to a raw JSON value.
+func (kv *KVParam) setRaw(key ParamKey, raw json.RawMessage) {
+	if kv.values == nil {
+		kv.values = make(map[ParamKey]json.RawMessage)
+	}
+	if _, ok := kv.values[key]; !ok {
+		kv.keys = append(kv.keys, key)
+	}
+	kv.values[key] = raw
+}
+
+// Keys returns the parameter names in order.
+func (kv KVParam) Keys() []ParamKey {
+	return append([]ParamKey(nil), kv.keys...)
+}
+
+// Clone returns a copy that can be changed without affecting kv. Copying a KVParam value
+// shares its parameters.
+func (kv KVParam) Clone() KVParam {
+	clone := KVParam{keys: kv.Keys(), values: make(map[ParamKey]json.RawMessage, len(kv.values))}
+	for key, raw := range kv.values {
+		clone.values[key] = append(json.RawMessage(nil), raw...)
+	}
+	return clone
+}
+
+// MarshalJSON writes the parameters as a JSON object, in order.
+func (kv KVParam) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range kv.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(kv.values[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a JSON object, keeping the order of its keys and the text of its values.
+func (kv *KVParam) UnmarshalJSON(data []byte) error {
+	*kv = KVParam{}
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("kvParam must be a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read kvParam key: %w", err)
+		}
+		key, _ := token.(string) // Object keys are always strings
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return fmt.Errorf("failed to read kvParam %s: %w", key, err)
+		}
+		kv.setRaw(ParamKey(key), raw)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("failed to read kvParam: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package creality
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+// MappingFile is the default name of the field mapping file in the data directory.
+const MappingFile = "mapping.json"
+
+//go:embed data/mapping.json
+var defaultMappingJSON []byte // How slicer settings become database fields unless overridden
+
+// Mapping describes how a normalized slicer profile becomes a database entry. Each rule fills
+// one field; kvParam keys are written in the order of their rules.
+type Mapping struct {
+	Rules []MappingRule `json:"rules"`
+}
+
+// MappingRule fills one field of a database entry. The value is taken from the first source
+// setting that is set and not empty, else computed by expr, and then converted to type. When
+// neither gives a value that converts, the template's value is kept, else default is used; a
+// kvParam key is written even then, while a base field without a usable default keeps its zero
+// value. Later rules can use the value of a base field as a variable named like its target,
+// e.g. base.weightPerMeter.
+type MappingRule struct {
+	Target   string  `json:"target"`             // "engineVersion", "printerIntName", "kvParam.<key>" or "base.<field>"
+	Source   keyList `json:"source,omitempty"`   // Slicer settings, tried in order; a kvParam rule without expr defaults to its own key
+	Expr     string  `json:"expr,omitempty"`     // Derived value such as "filament_cost / 1000", used when no source is set
+	Type     string  `json:"type,omitempty"`     // string (default), int, float, bool, percent or list
+	Decimals *int    `json:"decimals,omitempty"` // Rounds a float to this many decimals, 0 to whole numbers; unset keeps every digit
+	Default  string  `json:"default,omitempty"`  // Value used when the source is missing or does not convert
+	Omit     bool    `json:"omit,omitempty"`     // Do not write the field, e.g. to drop a default rule in an override
+}
+
+// keyList is a list of setting names that may be written as a single string.
+type keyList []string
+
+func (k *keyList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*k = keyList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("source must be a setting name or a list of them")
+	}
+	*k = list
+	return nil
+}
+
+// Conversions a rule may apply to its value.
+const (
+	typeString  = "string"
+	typeInt     = "int" // Decimals are rounded
+	typeFloat   = "float"
+	typeBool    = "bool"
+	typePercent = "percent" // A float written with or without a "%" sign, e.g. "99.5%"
+	typeList    = "list"    // Comma-separated strings
+)
+
+// baseFields maps the JSON names of the BaseInfo fields to their index.
+var baseFields = func() map[string]int {
+	fields := make(map[string]int)
+	baseType := reflect.TypeOf(BaseInfo{})
+	for i := 0; i < baseType.NumField(); i++ {
+		if !baseType.Field(i).IsExported() {
+			continue
+		}
+		fields[strings.Split(baseType.Field(i).Tag.Get("json"), ",")[0]] = i
+	}
+	return fields
+}()
+
+// DefaultMapping returns the embedded field mapping.
+func DefaultMapping() (*Mapping, error) {
+	var mapping Mapping
+	if err := json.Unmarshal(defaultMappingJSON, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded field mapping: %w", err)
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("embedded field mapping: %w", err)
+	}
+	return &mapping, nil
+}
+
+// LoadMapping returns the embedded field mapping with the rules of the file at path applied on
+// top: a rule replaces the default rule with the same target, other rules are added. A missing
+// file is not an error unless required is set; loaded tells whether the file was read.
+func LoadMapping(path string, required bool) (mapping *Mapping, loaded bool, err error) {
+	mapping, err = DefaultMapping()
+	if err != nil || path == "" {
+		return mapping, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return mapping, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read field mapping %s: %w", path, err)
+	}
+	var overrides Mapping
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, false, fmt.Errorf("failed to parse field mapping %s: %w", path, err)
+	}
+
+	position := make(map[string]int, len(mapping.Rules))
+	for i, rule := range mapping.Rules {
+		position[rule.Target] = i
+	}
+	for _, rule := range overrides.Rules {
+		if i, ok := position[rule.Target]; ok {
+			mapping.Rules[i] = rule
+		} else {
+			position[rule.Target] = len(mapping.Rules)
+			mapping.Rules = append(mapping.Rules, rule)
+		}
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, false, fmt.Errorf("field mapping %s: %w", path, err)
+	}
+	return mapping, true, nil
+}
+
+// validate checks the targets, types and expressions of the rules.
+func (m *Mapping) validate() error {
+	for _, rule := range m.Rules {
+		switch rule.Type {
+		case "", typeString, typeInt, typeFloat, typeBool, typePercent, typeList:
+		default:
+			return fmt.Errorf("%s: unknown type %q", rule.Target, rule.Type)
+		}
+		if rule.Decimals != nil && *rule.Decimals < 0 {
+			return fmt.Errorf("%s: decimals must not be negative, got %d", rule.Target, *rule.Decimals)
+		}
+		if rule.Expr != "" {
+			if err := profiles.CheckExpression(rule.Expr); err != nil {
+				return fmt.Errorf("%s: invalid expression %q: %v", rule.Target, rule.Expr, err)
+			}
+		}
+
+		section, name, _ := strings.Cut(rule.Target, ".")
+		switch {
+		case rule.Target == "engineVersion" || rule.Target == "printerIntName":
+			if rule.Type != "" && rule.Type != typeString {
+				return fmt.Errorf("%s is text, not %s", rule.Target, rule.Type)
+			}
+		case section == "kvParam" && name != "":
+			if rule.Type != "" && rule.Type != typeString {
+				return fmt.Errorf("%s: kvParam values are text, not %s", rule.Target, rule.Type)
+			}
+		case section == "base" && name != "":
+			index, ok := baseFields[name]
+			if !ok {
+				return fmt.Errorf("%s: base has no field %q", rule.Target, name)
+			}
+			if want := baseFieldType(reflect.TypeOf(BaseInfo{}).Field(index).Type); ruleType(rule) != want {
+				return fmt.Errorf("%s is of type %s, not %s", rule.Target, want, ruleType(rule))
+			}
+		default:
+			return fmt.Errorf("unknown target %q", rule.Target)
+		}
+	}
+	return nil
+}
+
+// ruleType returns the type of a rule, string when not given.
+func ruleType(rule MappingRule) string {
+	if rule.Type == "" {
+		return typeString
+	}
+	if rule.Type == typePercent {
+		return typeFloat
+	}
+	return rule.Type
+}
+
+// baseFieldType names the rule type matching a BaseInfo field.
+func baseFieldType(fieldType reflect.Type) string {
+	switch fieldType.Kind() {
+	case reflect.Int:
+		return typeInt
+	case reflect.Float64:
+		return typeFloat
+	case reflect.Bool:
+		return typeBool
+	case reflect.Slice:
+		return typeList
+	}
+	return typeString
+}
+
+// SourceKeys returns the slicer settings the rules read, directly or in expressions, so a
+// setting outside it is not synced.
+func (m *Mapping) SourceKeys() []string {
+	var keys []string
+	for _, rule := range m.Rules {
+		if rule.Omit {
+			continue
+		}
+		keys = append(keys, ruleSources(rule)...)
+		if rule.Expr != "" {
+			keys = append(keys, expressionNames(rule.Expr)...)
+		}
+	}
+	return keys
+}
+
+// ruleSources returns the settings a rule reads its value from.
+func ruleSources(rule MappingRule) []string {
+	if len(rule.Source) > 0 {
+		return rule.Source
+	}
+	if key, ok := strings.CutPrefix(rule.Target, "kvParam."); ok && rule.Expr == "" {
+		return []string{key}
+	}
+	return nil
+}
+
+// expressionNames returns the words of an expression that may name settings.
+func expressionNames(expression string) []string {
+	return strings.FieldsFunc(expression, func(r rune) bool {
+		return !(r == '_' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
+
+// apply fills the fields of entry from a normalized slicer profile. Fields entry already has,
+// taken from a template, are only replaced by values the profile sets.
+func (m *Mapping) apply(entry *FilamentProfileEntry, settings map[string]string) {
+	base := reflect.ValueOf(&entry.Base).Elem()
+	for _, rule := range m.Rules {
+		if rule.Omit {
+			continue
+		}
+		text, found := ruleValue(rule, settings)
+		section, name, _ := strings.Cut(rule.Target, ".")
+		switch section {
+		case "engineVersion":
+			if found || entry.EngineVersion == "" {
+				entry.EngineVersion = text
+			}
+		case "printerIntName":
+			if found || entry.PrinterIntName == "" {
+				entry.PrinterIntName = text
+			}
+		case "kvParam":
+			if found || !entry.KVParam.Has(ParamKey(name)) {
+				entry.KVParam.Set(ParamKey(name), text)
+			}
+		case "base":
+			field := base.Field(baseFields[name])
+			if rule.Type != typeList {
+				text = firstElement(text) // A base field holds one value, not one per extruder
+			}
+			value, ok := convertValue(text, rule.Type)
+			if !found || !ok {
+				value, ok = nil, false
+				if field.IsZero() {
+					value, ok = convertValue(rule.Default, rule.Type)
+				}
+			}
+			if ok {
+				if number, isFloat := value.(float64); isFloat && rule.Decimals != nil {
+					scale := math.Pow(10, float64(*rule.Decimals))
+					value = math.Round(number*scale) / scale
+				}
+				field.Set(reflect.ValueOf(value))
+			} // Otherwise the field keeps the template's or its zero value
+			if field.Kind() != reflect.Slice {
+				settings[rule.Target] = fmt.Sprint(field.Interface())
+			}
+		}
+	}
+}
+
+// ruleValue returns the text a rule yields before conversion: the first non-empty source
+// setting, else the expression's result, and whether there was one. Otherwise, including when
+// an expression refers to a setting the profile lacks or fails on its values, it returns the
+// default.
+func ruleValue(rule MappingRule, settings map[string]string) (string, bool) {
+	for _, key := range ruleSources(rule) {
+		if value := settings[key]; value != "" {
+			return value, true
+		}
+	}
+	if rule.Expr != "" {
+		if value, err := profiles.EvaluateExpression(rule.Expr, settings); err == nil {
+			return value, true
+		}
+	}
+	return rule.Default, false
+}
+
+// convertValue converts text to a value of the Go type a rule type stands for.
+func convertValue(text, valueType string) (interface{}, bool) {
+	text = strings.TrimSpace(text)
+	switch valueType {
+	case typeInt:
+		value, ok := parseNumber(text)
+		return int(math.Round(value)), ok
+	case typePercent:
+		return parseNumber(strings.TrimSpace(strings.TrimSuffix(text, "%")))
+	case typeFloat:
+		return parseNumber(text)
+	case typeBool:
+		return text == "1" || strings.EqualFold(text, "true"), true
+	case typeList:
+		if text == "" {
+			return nil, false
+		}
+		var list []string
+		for _, element := range strings.Split(text, ",") {
+			list = append(list, strings.TrimSpace(element))
+		}
+		return list, true
+	}
+	return text, true
+}
+
+// firstElement returns the first value of a per-extruder vector the way profiles.SerializeSetting
+// joins them: numbers and flags with commas, text with semicolons.
+func firstElement(text string) string {
+	elements := strings.Split(text, ",")
+	for _, element := range elements {
+		element = strings.TrimSpace(element)
+		_, numberErr := strconv.ParseFloat(strings.TrimSuffix(element, "%"), 64)
+		if _, err := strconv.ParseBool(element); err != nil && numberErr != nil {
+			first, _, _ := strings.Cut(text, ";")
+			return first
+		}
+	}
+	return elements[0]
+}
+
+// parseNumber parses a finite number; JSON has no NaN or infinity.
+func parseNumber(text string) (float64, bool) {
+	value, err := strconv.ParseFloat(text, 64)
+	return value, err == nil && !math.IsInf(value, 0) && !math.IsNaN(value)
+}
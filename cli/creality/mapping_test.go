@@ -0,0 +1,105 @@
+package creality
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+func TestConvertPerExtruderSettings(t *testing.T) {
+	settings := map[string]string{
+		"nozzle_temperature":            "220,225",
+		"nozzle_temperature_range_low":  "190,190",
+		"nozzle_temperature_range_high": "230,230",
+		"filament_density":              "1.24,1.24",
+		"filament_diameter":             "1.75,1.75",
+		"filament_cost":                 "25,25",
+		"filament_shrink":               "99.5%,99.5%",
+		"filament_soluble":              "0,0",
+		"filament_is_support":           "1,1",
+		"filament_type":                 "PLA;PLA",
+		"filament_start_gcode":          "M104 S210;M104 S220",
+		"filament_notes.id":             "90001",
+		"filament_notes.vendor":         "Acme",
+		"filament_notes.name":           "Acme PLA",
+		"filament_notes.type":           "PLA",
+	}
+	notes := &profiles.FilamentNotes{ID: "90001", Vendor: "Acme", Type: "PLA", Name: "Acme PLA"}
+
+	entry, err := ConvertToCrealityFormat(settings, notes, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entry.KVParam.Get("nozzle_temperature"); got != "220,225" {
+		t.Errorf("kvParam nozzle_temperature = %q, want the per-extruder values", got)
+	}
+	if got := entry.KVParam.Get("filament_start_gcode"); got != "M104 S210;M104 S220" {
+		t.Errorf("kvParam filament_start_gcode = %q, want the per-extruder values", got)
+	}
+
+	base := entry.Base
+	if base.MinTemp != 190 || base.MaxTemp != 230 || base.Density != 1.24 || base.Diameter != "1.75" {
+		t.Errorf("base temperatures, density and diameter = %d, %d, %v, %q, want the first extruder's", base.MinTemp, base.MaxTemp, base.Density, base.Diameter)
+	}
+	if base.ShrinkageRate != 99.5 || base.IsSoluble || !base.IsSupport {
+		t.Errorf("base shrinkage and flags = %v, %t, %t, want 99.5, false, true", base.ShrinkageRate, base.IsSoluble, base.IsSupport)
+	}
+	if base.WeightPerMeter != 2.98 || base.CostPerMeter != 0.0745 {
+		t.Errorf("base weight and cost per meter = %v, %v, want 2.98 and 0.0745", base.WeightPerMeter, base.CostPerMeter)
+	}
+}
+
+func TestMappingWholeNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), MappingFile)
+	overrides := `{"rules": [
+		{"target": "base.weightPerMeter", "expr": "filament_density * 3.14159265 * filament_diameter * filament_diameter / 4", "type": "float", "decimals": 0},
+		{"target": "base.costPerMeter", "expr": "filament_cost * base.weightPerMeter / 1000", "type": "float", "decimals": 0}
+	]}`
+	if err := os.WriteFile(path, []byte(overrides), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mapping, _, err := LoadMapping(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := map[string]string{"filament_density": "1.24", "filament_diameter": "1.75", "filament_cost": "250"}
+	notes := &profiles.FilamentNotes{ID: "90001", Vendor: "Acme", Type: "PLA", Name: "Acme PLA"}
+	entry, err := ConvertToCrealityFormat(settings, notes, mapping, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Base.WeightPerMeter != 3 || entry.Base.CostPerMeter != 1 {
+		t.Errorf("base weight and cost per meter = %v, %v, want 3 and 1", entry.Base.WeightPerMeter, entry.Base.CostPerMeter)
+	}
+}
+
+func TestMappingNegativeDecimals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), MappingFile)
+	if err := os.WriteFile(path, []byte(`{"rules": [{"target": "base.density", "source": "filament_density", "type": "float", "decimals": -1}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadMapping(path, true); err == nil {
+		t.Error("LoadMapping() accepted negative decimals")
+	}
+}
+
+func TestFirstElement(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"220", "220"},
+		{"220,230", "220"},
+		{"99%,98%", "99%"},
+		{"1,0", "1"},
+		{"true,false", "true"},
+		{"PLA;PETG", "PLA"},
+		{"Printer, Inc.", "Printer, Inc."},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := firstElement(tt.text); got != tt.want {
+			t.Errorf("firstElement(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
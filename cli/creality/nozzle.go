@@ -0,0 +1,161 @@
+package creality
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+// defaultNozzleDiameter is used when neither the notes nor compatible_printers name one.
+const defaultNozzleDiameter = "0.4"
+
+// nozzleInPrinterName finds the diameter in printer preset names such as "Creality K2 Plus 0.6 nozzle".
+var nozzleInPrinterName = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:mm)?\s*nozzle`)
+
+// NozzleDiameters returns the nozzle diameters a profile is for: the notes' nozzleDiameters,
+// else the diameters named in compatible_printers, else 0.4.
+func NozzleDiameters(slicerProfileData map[string]string, notes *profiles.FilamentNotes) []string {
+	if len(notes.NozzleDiameters) > 0 {
+		return append([]string(nil), notes.NozzleDiameters...)
+	}
+
+	seen := make(map[string]bool)
+	var diameters []string
+	for _, printerName := range strings.Split(slicerProfileData["compatible_printers"], ",") {
+		match := nozzleInPrinterName.FindStringSubmatch(printerName)
+		if match == nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+		diameter := strconv.FormatFloat(value, 'f', -1, 64)
+		if !seen[diameter] {
+			seen[diameter] = true
+			diameters = append(diameters, diameter)
+		}
+	}
+	if len(diameters) == 0 {
+		return []string{defaultNozzleDiameter}
+	}
+
+	sort.Slice(diameters, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(diameters[i], 64)
+		b, _ := strconv.ParseFloat(diameters[j], 64)
+		return a < b
+	})
+	return diameters
+}
+
+// ExpandNozzleDiameters returns the database entries for a converted profile. Without
+// nozzleOverrides the entry covers all its diameters at once; with them, one entry per
+// diameter is returned, each carrying the overrides for its diameter.
+func ExpandNozzleDiameters(entry *FilamentProfileEntry, overrides profiles.NozzleOverrides) ([]*FilamentProfileEntry, error) {
+	if len(overrides) == 0 {
+		return []*FilamentProfileEntry{entry}, nil
+	}
+
+	for diameter := range overrides {
+		if !containsString(entry.NozzleDiameter, diameter) {
+			return nil, fmt.Errorf("nozzleOverrides has settings for a %s mm nozzle, but the profile is for %s mm",
+				diameter, strings.Join(entry.NozzleDiameter, ", "))
+		}
+	}
+
+	var entries []*FilamentProfileEntry
+	for _, diameter := range entry.NozzleDiameter {
+		perNozzle := *entry
+		perNozzle.NozzleDiameter = []string{diameter}
+		perNozzle.KVParam = entry.KVParam.Clone() // The copy above shares the parameters
+		for key, value := range overrides[diameter] {
+			if err := setKVParam(&perNozzle.KVParam, key, value); err != nil {
+				return nil, fmt.Errorf("nozzleOverrides for %s mm: %w", diameter, err)
+			}
+		}
+		entries = append(entries, &perNozzle)
+	}
+	return entries, nil
+}
+
+// setKVParam sets the parameter with the given database key, e.g. "pressure_advance". Only
+// parameters the entry has, which are those of the field mapping, can be set.
+func setKVParam(kv *KVParam, key, value string) error {
+	if !kv.Has(ParamKey(key)) {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	kv.Set(ParamKey(key), value)
+	return nil
+}
+
+// ReplaceProfileEntries puts the entries of one profile into the database, removing every
+// existing entry with the same ID first so diameters dropped from the profile disappear too.
+func ReplaceProfileEntries(db *MaterialDatabase, entries []*FilamentProfileEntry, version string) {
+	if len(entries) == 0 {
+		return
+	}
+	id := entries[0].Base.ID
+
+	kept := db.Result.List[:0]
+	for _, existing := range db.Result.List {
+		if existing.Base.ID != id {
+			kept = append(kept, existing)
+		}
+	}
+	db.Result.List = kept
+	for _, entry := range entries {
+		db.Result.List = append(db.Result.List, *entry)
+	}
+	db.Result.Count = len(db.Result.List)
+	db.Result.Version = version
+}
+
+// FindEntries returns every entry with the given Base.ID, one per nozzle diameter at most.
+func FindEntries(db *MaterialDatabase, id string) []*FilamentProfileEntry {
+	var entries []*FilamentProfileEntry
+	for i := range db.Result.List {
+		if db.Result.List[i].Base.ID == id {
+			entries = append(entries, &db.Result.List[i])
+		}
+	}
+	return entries
+}
+
+// EntriesFingerprint returns a stable hash of the entries of one profile. A single entry has
+// the same fingerprint as EntryFingerprint, so earlier sync records stay valid.
+func EntriesFingerprint(entries []*FilamentProfileEntry) (string, error) {
+	if len(entries) == 1 {
+		return EntryFingerprint(entries[0])
+	}
+
+	sorted := append([]*FilamentProfileEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.Join(sorted[i].NozzleDiameter, ",") < strings.Join(sorted[j].NozzleDiameter, ",")
+	})
+	var fingerprints []string
+	for _, entry := range sorted {
+		fingerprint, err := EntryFingerprint(entry)
+		if err != nil {
+			return "", err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fingerprints, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package creality
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// unknownFields keeps what a struct does not declare of the JSON object it was read from: the
+// members it has no field for and the order of all members. A struct holding one and
+// (un)marshalling through readObject and writeObject is written back as it was read, so fields
+// added by a firmware update are not erased.
+type unknownFields struct {
+	order []string                   // Member names in the order they were read
+	extra map[string]json.RawMessage // Members the struct has no field for, as read
+}
+
+// readObject unmarshals the JSON object data into known, a pointer to a struct type without
+// custom unmarshalling, and records the rest in fields.
+func readObject(data []byte, known interface{}, fields *unknownFields) error {
+	if err := json.Unmarshal(data, known); err != nil {
+		return err
+	}
+	names, members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	declared := fieldNames(reflect.TypeOf(known).Elem())
+	*fields = unknownFields{order: names}
+	for _, name := range names {
+		if !containsFold(declared, name) {
+			if fields.extra == nil {
+				fields.extra = make(map[string]json.RawMessage)
+			}
+			fields.extra[name] = members[name]
+		}
+	}
+	return nil
+}
+
+// writeObject marshals known, a struct value without custom marshalling, as a JSON object with
+// the members in fields added back. Members come in the order they were read; fields the object
+// did not have follow in declaration order.
+func writeObject(known interface{}, fields unknownFields) ([]byte, error) {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false) // The caller's encoder decides about escaping
+	if err := encoder.Encode(known); err != nil {
+		return nil, err
+	}
+	names, members, err := decodeObject(encoded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	written := make(map[string]bool, len(names)+len(fields.extra))
+	write := func(name string, value json.RawMessage) {
+		if written[name] {
+			return
+		}
+		written[name] = true
+		if buf.Len() > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name) // Marshalling a string cannot fail
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	for _, name := range fields.order {
+		if value, ok := members[name]; ok {
+			write(name, value)
+		} else if value, ok := fields.extra[name]; ok {
+			write(name, value)
+		}
+	}
+	for _, name := range names {
+		write(name, members[name])
+	}
+	return append(append([]byte{'{'}, buf.Bytes()...), '}'), nil
+}
+
+// decodeObject returns the member names of a JSON object in order and their raw values.
+func decodeObject(data []byte) ([]string, map[string]json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected a JSON object")
+	}
+	var names []string
+	members := make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		name, _ := token.(string) // Object keys are always strings
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if _, seen := members[name]; !seen {
+			names = append(names, name)
+		}
+		members[name] = value
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+	return names, members, nil
+}
+
+// fieldNames returns the JSON names of the exported fields of a struct type.
+func fieldNames(structType reflect.Type) []string {
+	var names []string
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// containsFold reports whether values has value, ignoring case as JSON field matching does.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package creality
+
+import (
+	"fmt"
+	"strings"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+// TemplateNone in the notes' template field converts a profile without a template.
+const TemplateNone = "none"
+
+// FindTemplate returns the stock entry whose settings fill in what a profile does not set: the
+// one named by the notes' template field, else the best match for the profile's material type.
+// Among entries of that type, one of the same vendor comes first, then Generic ones, the plain
+// "Generic <type>" before its variants, then the database order. An entry for the profile's
+// first nozzle diameter is preferred throughout. It returns nil when no stock entry has the type.
+func FindTemplate(db *MaterialDatabase, slicerProfileData map[string]string, notes *profiles.FilamentNotes) (*FilamentProfileEntry, error) {
+	if strings.EqualFold(notes.Template, TemplateNone) {
+		return nil, nil
+	}
+	nozzle := NozzleDiameters(slicerProfileData, notes)[0]
+
+	if notes.Template != "" {
+		var found *FilamentProfileEntry
+		for i := range db.Result.List {
+			entry := &db.Result.List[i]
+			if entry.Base.ID != notes.Template || HasSyncNotes(entry) {
+				continue
+			}
+			if found == nil || (!containsString(found.NozzleDiameter, nozzle) && containsString(entry.NozzleDiameter, nozzle)) {
+				found = entry
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("template %s is not a stock filament of the baseline database", notes.Template)
+		}
+		return found, nil
+	}
+
+	materialType := notes.Type
+	if materialType == "" {
+		materialType, _, _ = strings.Cut(slicerProfileData["filament_type"], ";") // The first extruder's
+	}
+	if materialType == "" {
+		return nil, nil
+	}
+
+	var best *FilamentProfileEntry
+	bestScore := -1
+	for i := range db.Result.List {
+		entry := &db.Result.List[i]
+		if !strings.EqualFold(entry.Base.MaterialType, materialType) || HasSyncNotes(entry) {
+			continue
+		}
+		score := 0
+		switch {
+		case notes.Vendor != "" && strings.EqualFold(entry.Base.Brand, notes.Vendor):
+			score += 8
+		case strings.EqualFold(entry.Base.Brand, "Generic"):
+			score += 4
+			if strings.EqualFold(entry.Base.Name, "Generic "+materialType) {
+				score += 2
+			}
+		}
+		if containsString(entry.NozzleDiameter, nozzle) {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	return best, nil
+}
+
+// fromTemplate returns a new entry with the settings of template but none of its identity:
+// the ID, brand, name, type, colors and rank are left for the profile to fill.
+func fromTemplate(template *FilamentProfileEntry) *FilamentProfileEntry {
+	entry := &FilamentProfileEntry{
+		EngineVersion:  template.EngineVersion,
+		PrinterIntName: template.PrinterIntName,
+		KVParam:        template.KVParam.Clone(),
+		Base:           template.Base,
+	}
+	entry.Base.ID, entry.Base.Brand, entry.Base.Name, entry.Base.MaterialType = "", "", "", ""
+	entry.Base.Colors = nil
+	entry.Base.Rank = 0
+	return entry
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filament-sync-tool/cli/factory"
+	"filament-sync-tool/cli/scp"
+)
+
+// maxChangesShown limits the changes printed per file by factory-diff unless --all is set.
+const maxChangesShown = 20
+
+// readPrinterBoxFile downloads a file from the box directory, returning nil if it does not exist.
+func readPrinterBoxFile(scpClient *scp.SCPClient, fileName string) ([]byte, error) {
+	remotePath := filepath.Join(printerModel.BoxDir, fileName)
+	exists, err := scpClient.FileExists(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+	return scpClient.ReadFile(remotePath)
+}
+
+// runFactoryDiff reports how the printer's box directory drifted from the model's factory snapshot.
+func runFactoryDiff() {
+	snapshot, err := printerModel.Snapshot()
+	if err != nil {
+		log.Fatalf("Cannot diff against factory state: %v", err)
+	}
+	fileNames, err := printerModel.SnapshotFiles()
+	if err != nil {
+		log.Fatalf("Cannot diff against factory state: %v", err)
+	}
+
+	scpClient := connectPrinter()
+	defer scpClient.Close()
+
+	modified := 0
+	for _, fileName := range fileNames {
+		factoryData, err := fs.ReadFile(snapshot, fileName)
+		if err != nil {
+			log.Fatalf("Failed to read factory copy of %s: %v", fileName, err)
+		}
+		printerData, err := readPrinterBoxFile(scpClient, fileName)
+		if err != nil {
+			log.Fatalf("Failed to read %s from printer: %v", fileName, err)
+		}
+
+		diff := factory.DiffFile(fileName, factoryData, printerData)
+		fmt.Printf("%s: %s\n", diff.Name, diff.Status)
+		if diff.Status != factory.StatusIdentical {
+			modified++
+		}
+
+		for i, change := range diff.Changes {
+			if i == maxChangesShown && !appConfig.ShowAll {
+				fmt.Printf("    ... %d more, use --all to list them\n", len(diff.Changes)-maxChangesShown)
+				break
+			}
+			fmt.Printf("    %s\n", change)
+		}
+	}
+
+	fmt.Printf("\n%d of %d files differ from the %s factory snapshot.\n", modified, len(fileNames), printerModel.DisplayName)
+}
+
+// runFactoryReset restores the selected box files from the model's factory snapshot, after
+// backing up the printer's current copies into the data directory.
+func runFactoryReset() {
+	snapshot, err := printerModel.Snapshot()
+	if err != nil {
+		log.Fatalf("Cannot reset to factory state: %v", err)
+	}
+	available, err := printerModel.SnapshotFiles()
+	if err != nil {
+		log.Fatalf("Cannot reset to factory state: %v", err)
+	}
+
+	selected, err := selectSnapshotFiles(appConfig.Args, available)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if !appConfig.Yes && !confirm(fmt.Sprintf("Overwrite %s on %s (%s) with the factory copies?", strings.Join(selected, ", "), appConfig.PrinterIP, printerModel.DisplayName)) {
+		log.Println("Factory reset cancelled.")
+		return
+	}
+
+	scpClient := connectPrinter()
+	defer scpClient.Close()
+
+	// Restoring box files during a job is as risky as a sync
+	if !printerReadyForSync(scpClient) {
+		log.Println("Factory reset postponed: the printer is busy or its state is unknown. Try again later, or use --force to override.")
+		return
+	}
+
+	backupDir := filepath.Join(appConfig.DataDir, "backups", appConfig.PrinterIP, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		log.Fatalf("Failed to create backup directory %s: %v", backupDir, err)
+	}
+
+	for _, fileName := range selected {
+		printerData, err := readPrinterBoxFile(scpClient, fileName)
+		if err != nil {
+			log.Fatalf("Failed to back up %s from printer: %v", fileName, err)
+		}
+		if printerData != nil {
+			if err := os.WriteFile(filepath.Join(backupDir, fileName), printerData, 0644); err != nil {
+				log.Fatalf("Failed to write backup of %s: %v", fileName, err)
+			}
+		}
+
+		factoryData, err := fs.ReadFile(snapshot, fileName)
+		if err != nil {
+			log.Fatalf("Failed to read factory copy of %s: %v", fileName, err)
+		}
+		if err := uploadToBox(scpClient, fileName, factoryData); err != nil {
+			log.Fatalf("Failed to restore %s: %v", fileName, err)
+		}
+		log.Printf("Restored %s from the factory snapshot.", fileName)
+	}
+
+	log.Printf("Factory reset complete. Previous copies saved in %s", backupDir)
+}
+
+// selectSnapshotFiles validates the file names given on the command line; "all" selects every file.
+func selectSnapshotFiles(args, available []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("factory-reset needs the files to restore, or \"all\". Available: %s", strings.Join(available, ", "))
+	}
+
+	for _, arg := range args {
+		if arg == "all" {
+			return available, nil
+		}
+	}
+
+	for _, arg := range args {
+		found := false
+		for _, name := range available {
+			if arg == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s is not part of the %s factory snapshot. Available: %s", arg, printerModel.DisplayName, strings.Join(available, ", "))
+		}
+	}
+	return args, nil
+}
+
+// confirm asks a yes/no question on the terminal; anything but "y" or "yes" declines.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,288 @@
+package factory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FileStatus is the drift state of one file in the box directory.
+type FileStatus string
+
+const (
+	StatusIdentical        FileStatus = "identical"
+	StatusModified         FileStatus = "modified"
+	StatusMissingOnPrinter FileStatus = "missing on printer"
+)
+
+// databaseFile is diffed entry by entry instead of by list position.
+const databaseFile = "material_database.json"
+
+// maxValueLength truncates long values (G-code snippets) in change descriptions.
+const maxValueLength = 60
+
+// FileDiff describes how a printer file differs from its factory copy.
+type FileDiff struct {
+	Name    string
+	Status  FileStatus
+	Changes []string // One human-readable line per difference
+}
+
+// DiffFile compares the printer's copy of a file with the factory snapshot.
+// printerData is nil when the file does not exist on the printer.
+func DiffFile(name string, factoryData, printerData []byte) FileDiff {
+	diff := FileDiff{Name: name, Status: StatusIdentical}
+	if printerData == nil {
+		diff.Status = StatusMissingOnPrinter
+		return diff
+	}
+
+	var factoryValue, printerValue interface{}
+	factoryErr := json.Unmarshal(factoryData, &factoryValue)
+	printerErr := json.Unmarshal(printerData, &printerValue)
+	if factoryErr != nil || printerErr != nil {
+		// Not JSON (or corrupted on the printer): fall back to a byte comparison
+		if !bytes.Equal(bytes.TrimSpace(factoryData), bytes.TrimSpace(printerData)) {
+			diff.Status = StatusModified
+			if printerErr != nil {
+				diff.Changes = append(diff.Changes, fmt.Sprintf("printer copy is not valid JSON: %v", printerErr))
+			} else {
+				diff.Changes = append(diff.Changes, "content differs")
+			}
+		}
+		return diff
+	}
+
+	if name == databaseFile {
+		diff.Changes = diffDatabase(factoryValue, printerValue)
+	} else {
+		diffJSON("", factoryValue, printerValue, &diff.Changes)
+	}
+	if len(diff.Changes) > 0 {
+		diff.Status = StatusModified
+	}
+	return diff
+}
+
+// diffDatabase compares material databases entry by entry, keyed by base.id. Entries that
+// share an ID, such as the per-nozzle copies of a synced profile, are told apart by their
+// nozzle diameters.
+func diffDatabase(factoryValue, printerValue interface{}) []string {
+	factoryEntries, factoryOrder := indexEntries(factoryValue)
+	printerEntries, printerOrder := indexEntries(printerValue)
+	if factoryEntries == nil || printerEntries == nil {
+		// Unexpected layout, compare as plain JSON
+		var changes []string
+		diffJSON("", factoryValue, printerValue, &changes)
+		return changes
+	}
+
+	var changes []string
+	for _, id := range factoryOrder {
+		factoryGroup, printerGroup := factoryEntries[id], printerEntries[id]
+		shared := len(factoryGroup) > 1 || len(printerGroup) > 1
+		if !shared && len(printerGroup) == 1 {
+			changes = append(changes, diffEntry(id, factoryGroup[0], printerGroup[0], false)...)
+			continue
+		}
+
+		matched := make([]bool, len(printerGroup))
+		for _, factoryEntry := range factoryGroup {
+			found := -1
+			for i, printerEntry := range printerGroup {
+				if !matched[i] && entryNozzles(printerEntry) == entryNozzles(factoryEntry) {
+					found = i
+					break
+				}
+			}
+			if found < 0 {
+				changes = append(changes, fmt.Sprintf("entry %s: removed", describeEntry(id, factoryEntry, shared)))
+				continue
+			}
+			matched[found] = true
+			changes = append(changes, diffEntry(id, factoryEntry, printerGroup[found], shared)...)
+		}
+		for i, printerEntry := range printerGroup {
+			if !matched[i] {
+				changes = append(changes, fmt.Sprintf("entry %s: added", describeEntry(id, printerEntry, shared)))
+			}
+		}
+	}
+	for _, id := range printerOrder {
+		if _, ok := factoryEntries[id]; ok {
+			continue
+		}
+		for _, printerEntry := range printerEntries[id] {
+			changes = append(changes, fmt.Sprintf("entry %s: added", describeEntry(id, printerEntry, len(printerEntries[id]) > 1)))
+		}
+	}
+	return changes
+}
+
+// diffEntry returns one line per difference between two copies of a database entry.
+func diffEntry(id string, factoryEntry, printerEntry interface{}, showNozzles bool) []string {
+	var entryChanges, changes []string
+	diffJSON("", factoryEntry, printerEntry, &entryChanges)
+	for _, change := range entryChanges {
+		changes = append(changes, fmt.Sprintf("entry %s: %s", describeEntry(id, printerEntry, showNozzles), change))
+	}
+	return changes
+}
+
+// describeEntry names an entry as `id (brand name)`, adding its nozzle diameters when other
+// entries share the ID.
+func describeEntry(id string, entry interface{}, showNozzles bool) string {
+	if showNozzles {
+		return fmt.Sprintf("%s (%s, %s mm nozzle)", id, entryLabel(entry), entryNozzles(entry))
+	}
+	return fmt.Sprintf("%s (%s)", id, entryLabel(entry))
+}
+
+// indexEntries groups result.list entries by base.id, returning nil if the layout is unexpected.
+func indexEntries(database interface{}) (map[string][]interface{}, []string) {
+	root, ok := database.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	result, ok := root["result"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	list, ok := result["list"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	entries := make(map[string][]interface{}, len(list))
+	var order []string
+	for _, item := range list {
+		id := entryID(item)
+		if _, seen := entries[id]; !seen {
+			order = append(order, id)
+		}
+		entries[id] = append(entries[id], item)
+	}
+	return entries, order
+}
+
+// entryNozzles returns the nozzle diameters of a database entry, e.g. "0.4/0.6".
+func entryNozzles(entry interface{}) string {
+	var diameters []string
+	if fields, ok := entry.(map[string]interface{}); ok {
+		if list, ok := fields["nozzleDiameter"].([]interface{}); ok {
+			for _, diameter := range list {
+				diameters = append(diameters, fmt.Sprintf("%v", diameter))
+			}
+		}
+	}
+	if len(diameters) == 0 {
+		return "?"
+	}
+	return strings.Join(diameters, "/")
+}
+
+// entryID returns base.id of a database entry.
+func entryID(entry interface{}) string {
+	if fields, ok := entry.(map[string]interface{}); ok {
+		if base, ok := fields["base"].(map[string]interface{}); ok {
+			return fmt.Sprintf("%v", base["id"])
+		}
+	}
+	return "?"
+}
+
+// entryLabel returns "brand name" of a database entry.
+func entryLabel(entry interface{}) string {
+	if fields, ok := entry.(map[string]interface{}); ok {
+		if base, ok := fields["base"].(map[string]interface{}); ok {
+			return fmt.Sprintf("%v %v", base["brand"], base["name"])
+		}
+	}
+	return "?"
+}
+
+// diffJSON appends one line per leaf value that differs between a and b.
+func diffJSON(path string, a, b interface{}, changes *[]string) {
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make(map[string]bool)
+		for key := range aMap {
+			keys[key] = true
+		}
+		for key := range bMap {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			childPath := joinPath(path, key)
+			aValue, inA := aMap[key]
+			bValue, inB := bMap[key]
+			switch {
+			case !inB:
+				*changes = append(*changes, fmt.Sprintf("%s: removed", childPath))
+			case !inA:
+				*changes = append(*changes, fmt.Sprintf("%s: added (%s)", childPath, formatValue(bValue)))
+			default:
+				diffJSON(childPath, aValue, bValue, changes)
+			}
+		}
+		return
+	}
+
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		if len(aList) != len(bList) {
+			*changes = append(*changes, fmt.Sprintf("%s: %d items instead of %d", displayPath(path), len(bList), len(aList)))
+			return
+		}
+		for i := range aList {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), aList[i], bList[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", displayPath(path), formatValue(a), formatValue(b)))
+	}
+}
+
+// joinPath appends a key to a dotted JSON path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// displayPath names the document root when the path is empty.
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// formatValue renders a JSON value on one line, truncated to maxValueLength.
+func formatValue(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // Keep G-code comparisons such as "a > b" readable
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	text := strings.ReplaceAll(strings.TrimSpace(buf.String()), "\n", " ")
+	if len(text) > maxValueLength {
+		text = text[:maxValueLength] + "..."
+	}
+	return text
+}
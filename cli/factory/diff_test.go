@@ -0,0 +1,64 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFileDatabase(t *testing.T) {
+	factory := []byte(`{"result":{"list":[
+		{"nozzleDiameter":["0.4"],"kvParam":{"nozzle_temperature":"220"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}},
+		{"nozzleDiameter":["0.4"],"kvParam":{"nozzle_temperature":"250"},"base":{"id":"01002","brand":"Creality","name":"Hyper PETG"}}
+	]}}`)
+	printer := []byte(`{"result":{"list":[
+		{"nozzleDiameter":["0.4"],"kvParam":{"nozzle_temperature":"225"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}},
+		{"nozzleDiameter":["0.4"],"kvParam":{"nozzle_temperature":"230"},"base":{"id":"90001","brand":"Acme","name":"PLA"}},
+		{"nozzleDiameter":["0.6"],"kvParam":{"nozzle_temperature":"235"},"base":{"id":"90001","brand":"Acme","name":"PLA"}}
+	]}}`)
+
+	diff := DiffFile(databaseFile, factory, printer)
+	want := []string{
+		"entry 01001 (Creality Hyper PLA): kvParam.nozzle_temperature: \"220\" -> \"225\"",
+		"entry 01002 (Creality Hyper PETG): removed",
+		"entry 90001 (Acme PLA, 0.4 mm nozzle): added",
+		"entry 90001 (Acme PLA, 0.6 mm nozzle): added",
+	}
+	if diff.Status != StatusModified || !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("DiffFile() = %s %q, want %q", diff.Status, diff.Changes, want)
+	}
+}
+
+func TestDiffFileDatabasePerNozzleEntries(t *testing.T) {
+	factory := []byte(`{"result":{"list":[
+		{"nozzleDiameter":["0.4"],"kvParam":{"pressure_advance":"0.04"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}},
+		{"nozzleDiameter":["0.6"],"kvParam":{"pressure_advance":"0.03"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}},
+		{"nozzleDiameter":["0.8"],"kvParam":{"pressure_advance":"0.02"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}}
+	]}}`)
+	// The printer lists the copies in another order, changed the 0.4 one and lost the 0.8 one
+	printer := []byte(`{"result":{"list":[
+		{"nozzleDiameter":["0.6"],"kvParam":{"pressure_advance":"0.03"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}},
+		{"nozzleDiameter":["0.4"],"kvParam":{"pressure_advance":"0.05"},"base":{"id":"01001","brand":"Creality","name":"Hyper PLA"}}
+	]}}`)
+
+	diff := DiffFile(databaseFile, factory, printer)
+	want := []string{
+		"entry 01001 (Creality Hyper PLA, 0.4 mm nozzle): kvParam.pressure_advance: \"0.04\" -> \"0.05\"",
+		"entry 01001 (Creality Hyper PLA, 0.8 mm nozzle): removed",
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("DiffFile() = %q, want %q", diff.Changes, want)
+	}
+
+	if diff := DiffFile(databaseFile, factory, factory); diff.Status != StatusIdentical || len(diff.Changes) > 0 {
+		t.Errorf("DiffFile() of identical databases = %s %q", diff.Status, diff.Changes)
+	}
+}
+
+func TestDiffFileNotJSON(t *testing.T) {
+	if diff := DiffFile("material_option.json", []byte(`{"a":1}`), nil); diff.Status != StatusMissingOnPrinter {
+		t.Errorf("DiffFile() of a missing file = %s", diff.Status)
+	}
+	if diff := DiffFile("material_option.json", []byte(`{"a":1}`), []byte(`{"a":`)); diff.Status != StatusModified || len(diff.Changes) != 1 {
+		t.Errorf("DiffFile() of a corrupted file = %s %q", diff.Status, diff.Changes)
+	}
+}
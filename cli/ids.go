@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"filament-sync-tool/cli/profiles"
+	"filament-sync-tool/cli/state"
+)
+
+// unassignedProfile is a slicer profile without sync metadata, with the notes it would get.
+type unassignedProfile struct {
+	Path  string
+	Notes *profiles.FilamentNotes
+}
+
+// runIDs dispatches the "ids" subcommands.
+func runIDs() {
+	subcommand := ""
+	if len(appConfig.Args) > 0 {
+		subcommand = appConfig.Args[0]
+	}
+
+	switch subcommand {
+	case "assign":
+		assignIDs()
+	default:
+		log.Fatalf("Unknown ids subcommand %q, expected \"assign\"", subcommand)
+	}
+}
+
+// assignIDs gives every selected profile without sync metadata a free ID from --id-range and
+// writes it into the profile's filament_notes.
+func assignIDs() {
+	profileFilter, err := profiles.NewFilter(appConfig.Include, appConfig.Exclude)
+	if err != nil {
+		log.Fatalf("Invalid profile filter: %v", err)
+	}
+
+	filePaths, err := profiles.ListProfileFiles(appConfig.ProfilePaths, appConfig.Recursive)
+	if err != nil {
+		log.Fatalf("Error listing profiles: %v", err)
+	}
+
+	// IDs already in use locally or by stock filaments are never handed out
+	used := make(map[string]bool)
+	for _, entry := range materialDB.Result.List {
+		used[entry.Base.ID] = true
+	}
+
+	var candidates []string
+	for _, path := range filePaths {
+		raw, err := profiles.ReadRawProfile(path)
+		if err != nil {
+			log.Printf("Skipping %s: %v", path, err)
+			continue
+		}
+
+		notes, err := profiles.NotesFromProfile(raw)
+		switch {
+		case err == nil:
+			used[notes.ID] = true
+		case errors.Is(err, profiles.ErrNoNotesMetadata):
+			candidates = append(candidates, path)
+		default:
+			// Broken metadata needs a human; overwriting it could lose the intended ID
+			log.Printf("Skipping %s: %v", path, err)
+		}
+	}
+
+	// Vendor and type are often inherited, so resolve them before writing the notes
+	var pending []unassignedProfile
+	if len(candidates) > 0 {
+		resolver := newPresetResolver(appConfig.ProfilePaths, candidates)
+		presetIdentity := &profiles.Identity{Mode: profiles.IdentityPreset}
+		for _, path := range candidates {
+			slicerProfile, err := profiles.ReadSlicerProfile(path)
+			if err != nil {
+				log.Printf("Skipping %s: %v", path, err)
+				continue
+			}
+			if err := resolver.Resolve(slicerProfile); err != nil {
+				log.Printf("Warning: profile %s is only partly resolved: %v", path, err)
+			}
+			notes, err := presetIdentity.Notes(slicerProfile.RawData, path)
+			if err != nil {
+				log.Printf("Skipping %s: %v", path, err)
+				continue
+			}
+			notes.ID = ""
+			if selected, reason := profileFilter.Match(path, notes); !selected {
+				log.Printf("Skipping %s: %s", path, reason)
+				continue
+			}
+			pending = append(pending, unassignedProfile{Path: path, Notes: notes})
+		}
+	}
+
+	if len(pending) == 0 {
+		log.Println("Every selected profile already has an ID.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VENDOR\tTYPE\tNAME\tFILE")
+	for _, profile := range pending {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", profile.Notes.Vendor, profile.Notes.Type, profile.Notes.Name, profile.Path)
+	}
+	w.Flush()
+
+	// The slicer rewrites presets it has open, so it should be closed while the notes change
+	question := fmt.Sprintf("Assign IDs from %05d-%05d to these %d profiles and write them into their notes? Close the slicer first.",
+		appConfig.IDRangeFirst, appConfig.IDRangeLast, len(pending))
+	if !appConfig.Yes && !confirm(question) {
+		log.Println("ID assignment cancelled.")
+		return
+	}
+
+	registry, err := state.LoadRegistry(appConfig.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to load the ID registry: %v", err)
+	}
+
+	backupDir := filepath.Join(appConfig.DataDir, "backups", "profiles", time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		log.Fatalf("Failed to create backup directory %s: %v", backupDir, err)
+	}
+
+	assigned := 0
+	for i, profile := range pending {
+		id, err := registry.Allocate(appConfig.IDRangeFirst, appConfig.IDRangeLast, used, state.Allocation{
+			Profile:    profile.Notes.Name,
+			File:       profile.Path,
+			AssignedAt: time.Now().UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			log.Printf("Stopping: %v", err)
+			break
+		}
+
+		// Numbered so presets with the same file name in different directories do not clash
+		original, err := os.ReadFile(profile.Path)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(backupDir, fmt.Sprintf("%03d-%s", i+1, filepath.Base(profile.Path))), original, 0644)
+		}
+		if err != nil {
+			registry.Release(id)
+			log.Printf("Skipping %s: failed to back it up: %v", profile.Path, err)
+			continue
+		}
+
+		profile.Notes.ID = id
+		if err := profiles.WriteNotes(profile.Path, profile.Notes); err != nil {
+			registry.Release(id)
+			log.Printf("Skipping %s: %v", profile.Path, err)
+			continue
+		}
+		used[id] = true
+		assigned++
+		log.Printf("Assigned id %s to %s (%s)", id, profile.Notes.Name, profile.Path)
+	}
+
+	if err := registry.Save(appConfig.DataDir); err != nil {
+		log.Fatalf("Failed to save the ID registry: %v", err)
+	}
+	log.Printf("Assigned %d IDs. Previous copies saved in %s", assigned, backupDir)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"filament-sync-tool/cli/lint"
+	"filament-sync-tool/cli/profiles"
+)
+
+// runLint checks every profile in the profile directories and prints the findings in
+// --format. It exits with status 1 when any finding is an error, so CI jobs fail.
+func runLint() {
+	filePaths, err := profiles.ListProfileFiles(appConfig.ProfilePaths, appConfig.Recursive)
+	if err != nil {
+		log.Fatalf("Error listing profiles: %v", err)
+	}
+
+	checker := &lint.Checker{
+		Identity:      loadIdentity(),
+		Resolver:      newPresetResolver(appConfig.ProfilePaths, filePaths),
+		MaterialTypes: knownMaterialTypes(),
+		SyncedKeys:    make(map[string]bool),
+	}
+	for _, key := range loadMapping().SourceKeys() {
+		checker.SyncedKeys[key] = true
+	}
+
+	findings := checker.Check(filePaths)
+	if err := lint.Write(os.Stdout, appConfig.Format, findings, len(filePaths)); err != nil {
+		log.Fatalf("Failed to write the lint report: %v", err)
+	}
+	if lint.HasErrors(findings) {
+		os.Exit(1)
+	}
+}
+
+// knownMaterialTypes returns the material types of the baseline database and options, upper case.
+func knownMaterialTypes() map[string]bool {
+	types := make(map[string]bool)
+	for _, entry := range materialDB.Result.List {
+		types[strings.ToUpper(entry.Base.MaterialType)] = true
+	}
+	for _, brandTypes := range materialOptions {
+		for materialType := range brandTypes {
+			types[strings.ToUpper(materialType)] = true
+		}
+	}
+	return types
+}
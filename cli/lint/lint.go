@@ -0,0 +1,249 @@
+// Package lint checks slicer filament profiles for problems before they are synced.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+// Level is the severity of a finding. Errors make the lint command fail.
+type Level string
+
+const (
+	LevelError   Level = "error"
+	LevelWarning Level = "warning"
+)
+
+// Rule is one kind of problem the checker reports.
+type Rule struct {
+	ID          string
+	Level       Level
+	Description string
+}
+
+// Rules lists every check, in the order they run.
+var Rules = []Rule{
+	{ID: "unreadable", Level: LevelError, Description: "The profile file cannot be read or parsed"},
+	{ID: "invalid-notes", Level: LevelError, Description: "filament_notes holds sync metadata that cannot be parsed or is invalid"},
+	{ID: "unresolved-parent", Level: LevelWarning, Description: "A preset in the inherits chain was not found, so inherited settings are missing"},
+	{ID: "missing-temperature", Level: LevelError, Description: "A temperature the printer needs is not set by the profile or its parents"},
+	{ID: "invalid-number", Level: LevelError, Description: "A setting that must be a number is nil, empty or not a number"},
+	{ID: "type-mismatch", Level: LevelWarning, Description: "The type in the notes differs from filament_type"},
+	{ID: "unknown-type", Level: LevelWarning, Description: "The material type is not one the printer knows"},
+	{ID: "duplicate-id", Level: LevelError, Description: "Another profile uses the same ID; only one of them is synced"},
+	{ID: "duplicate-name", Level: LevelWarning, Description: "Another profile has the same vendor and name, so they look alike on the printer"},
+	{ID: "dropped-settings", Level: LevelWarning, Description: "Settings the printer database has no field for are not synced"},
+}
+
+// Finding is one problem in one profile.
+type Finding struct {
+	Rule    string `json:"rule"`
+	Level   Level  `json:"level"`
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+// requiredTemperatures must be set somewhere in the inherits chain; the built-in defaults
+// are no substitute for them.
+var requiredTemperatures = []string{"nozzle_temperature", "nozzle_temperature_initial_layer", "hot_plate_temp", "hot_plate_temp_initial_layer"}
+
+// numericSettings must hold numbers when present. Other settings, such as the retraction
+// overrides, use nil to mean "take the printer's value".
+var numericSettings = []string{
+	"nozzle_temperature", "nozzle_temperature_initial_layer", "nozzle_temperature_range_low", "nozzle_temperature_range_high",
+	"hot_plate_temp", "hot_plate_temp_initial_layer", "textured_plate_temp", "textured_plate_temp_initial_layer",
+	"filament_density", "filament_diameter", "filament_cost", "filament_flow_ratio", "filament_max_volumetric_speed",
+	"fan_max_speed", "fan_min_speed",
+}
+
+// metadataKeys describe the preset rather than the filament, so not syncing them loses nothing.
+var metadataKeys = map[string]bool{
+	"name": true, "inherits": true, "version": true, "from": true, "instantiation": true, "setting_id": true,
+	"filament_id": true, "filament_settings_id": true, "filament_notes": true, "is_custom_defined": true,
+	"type": true, "base_id": true, "updated_time": true, "user_id": true, "filament_extruder_variant": true,
+}
+
+// Checker runs the checks over a set of profiles.
+type Checker struct {
+	Identity      *profiles.Identity // Decides which profiles are synced and with which ID
+	Resolver      *profiles.Resolver // Follows inherits chains
+	MaterialTypes map[string]bool    // Material types the printer knows, upper case
+	SyncedKeys    map[string]bool    // Settings a database entry keeps
+}
+
+// lintedProfile is a profile that passed the per-file checks, kept for the cross-file ones.
+type lintedProfile struct {
+	path  string
+	notes *profiles.FilamentNotes
+}
+
+// Check lints the given profile files. Files without sync metadata are not synced and are
+// skipped. Findings are ordered by file, then by rule.
+func (c *Checker) Check(filePaths []string) []Finding {
+	var findings []Finding
+	var linted []lintedProfile
+	for _, path := range filePaths {
+		fileFindings, notes := c.checkFile(path)
+		findings = append(findings, fileFindings...)
+		if notes != nil {
+			linted = append(linted, lintedProfile{path: path, notes: notes})
+		}
+	}
+	findings = append(findings, checkDuplicates(linted)...)
+
+	ruleOrder := make(map[string]int, len(Rules))
+	for i, rule := range Rules {
+		ruleOrder[rule.ID] = i
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return ruleOrder[findings[i].Rule] < ruleOrder[findings[j].Rule]
+	})
+	return findings
+}
+
+// checkFile runs the per-file checks and returns the profile's notes if it is synced.
+func (c *Checker) checkFile(path string) ([]Finding, *profiles.FilamentNotes) {
+	var findings []Finding
+	report := func(rule, format string, args ...interface{}) {
+		findings = append(findings, Finding{Rule: rule, Level: ruleLevel(rule), File: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	profile, err := profiles.ReadSlicerProfile(path)
+	if err != nil {
+		report("unreadable", "%v", err)
+		return findings, nil
+	}
+
+	notes, err := c.Identity.Notes(profile.RawData, path)
+	if errors.Is(err, profiles.ErrNoNotesMetadata) {
+		return nil, nil
+	}
+	if err != nil {
+		report("invalid-notes", "%v", err)
+		return findings, nil
+	}
+
+	// Settings the file sets itself, before inheritance fills in the rest
+	var ownKeys []string
+	for key := range profile.RawData {
+		ownKeys = append(ownKeys, key)
+	}
+
+	// A missing parent may well hold the temperatures, so only complete chains are checked for them
+	if err := c.Resolver.ResolveInherited(profile); err != nil {
+		report("unresolved-parent", "%v", err)
+	} else {
+		for _, key := range requiredTemperatures {
+			if _, ok := profile.RawData[key]; !ok {
+				report("missing-temperature", "%s is not set by the profile or the presets it inherits from", key)
+			}
+		}
+	}
+
+	for _, key := range numericSettings {
+		value, ok := profile.RawData[key]
+		if !ok {
+			continue
+		}
+		serialized, _ := profiles.SerializeSetting(key, value)
+		for _, element := range strings.Split(serialized, ",") {
+			if _, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(element), "%"), 64); err != nil {
+				report("invalid-number", "%s is %q, expected a number", key, serialized)
+				break
+			}
+		}
+	}
+
+	filamentType := firstString(profile.RawData["filament_type"])
+	if notes.Type != "" && filamentType != "" && !strings.EqualFold(notes.Type, filamentType) {
+		report("type-mismatch", "the notes say %q but filament_type is %q", notes.Type, filamentType)
+	}
+	materialType := notes.Type
+	if materialType == "" {
+		materialType = filamentType
+	}
+	if materialType != "" && len(c.MaterialTypes) > 0 && !c.MaterialTypes[strings.ToUpper(materialType)] {
+		report("unknown-type", "material type %q is not in the printer's database", materialType)
+	}
+
+	var dropped []string
+	for _, key := range ownKeys {
+		if !c.SyncedKeys[key] && !metadataKeys[key] {
+			dropped = append(dropped, key)
+		}
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		report("dropped-settings", "not synced: %s", strings.Join(dropped, ", "))
+	}
+
+	return findings, notes
+}
+
+// checkDuplicates reports IDs and vendor/name pairs used by more than one profile. The first
+// profile keeps them; every later one is reported.
+func checkDuplicates(linted []lintedProfile) []Finding {
+	var findings []Finding
+	idOwner := make(map[string]string)
+	nameOwner := make(map[string]string)
+	for _, profile := range linted {
+		if owner, ok := idOwner[profile.notes.ID]; ok {
+			findings = append(findings, Finding{Rule: "duplicate-id", Level: ruleLevel("duplicate-id"), File: profile.path,
+				Message: fmt.Sprintf("id %s is also used by %s", profile.notes.ID, owner)})
+		} else {
+			idOwner[profile.notes.ID] = profile.path
+		}
+
+		name := strings.ToLower(profile.notes.Vendor + "\x00" + profile.notes.Name)
+		if owner, ok := nameOwner[name]; ok {
+			findings = append(findings, Finding{Rule: "duplicate-name", Level: ruleLevel("duplicate-name"), File: profile.path,
+				Message: fmt.Sprintf("%s %s is also the name of %s", profile.notes.Vendor, profile.notes.Name, owner)})
+		} else {
+			nameOwner[name] = profile.path
+		}
+	}
+	return findings
+}
+
+// ruleLevel returns the level of the rule with the given ID.
+func ruleLevel(id string) Level {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule.Level
+		}
+	}
+	return LevelWarning
+}
+
+// HasErrors reports whether any finding is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// firstString returns a string value, or the first element of a string array, trimmed.
+func firstString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
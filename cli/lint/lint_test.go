@@ -0,0 +1,161 @@
+package lint
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// profile returns a complete, valid preset; overrides replace or, when nil, remove its keys.
+func profile(notes string, overrides map[string]string) string {
+	settings := map[string]string{
+		"name":                             `"Acme PLA"`,
+		"filament_notes":                   `[` + quote(notes) + `]`,
+		"filament_type":                    `["PLA"]`,
+		"nozzle_temperature":               `["210"]`,
+		"nozzle_temperature_initial_layer": `["215"]`,
+		"hot_plate_temp":                   `["60"]`,
+		"hot_plate_temp_initial_layer":     `["65"]`,
+	}
+	for key, value := range overrides {
+		if value == "" {
+			delete(settings, key)
+		} else {
+			settings[key] = value
+		}
+	}
+	var members []string
+	for key, value := range settings {
+		members = append(members, quote(key)+": "+value)
+	}
+	return "{" + strings.Join(members, ", ") + "}"
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+const acmeNotes = `{"id":"90001","vendor":"Acme","type":"PLA","name":"Acme PLA"}`
+
+func newChecker(t *testing.T, dir string) *Checker {
+	t.Helper()
+	identity, err := profiles.NewIdentity(profiles.IdentityNotes, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := profiles.NewResolver([]string{dir}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Checker{
+		Identity:      identity,
+		Resolver:      resolver,
+		MaterialTypes: map[string]bool{"PLA": true, "PETG": true},
+		SyncedKeys: map[string]bool{
+			"filament_type": true, "nozzle_temperature": true, "nozzle_temperature_initial_layer": true,
+			"hot_plate_temp": true, "hot_plate_temp_initial_layer": true, "filament_density": true,
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string // Written as a.json, b.json, ... in this order
+		want  []string // Rule of every finding, in report order
+	}{
+		{"clean profile", []string{profile(acmeNotes, nil)}, nil},
+		{"profile without metadata is skipped", []string{`{"name":"Plain PLA","filament_type":["PLA"]}`}, nil},
+		{"unreadable", []string{`{"name": "Acme PLA",`}, []string{"unreadable"}},
+		{"invalid-notes", []string{profile(`{"id":"90001","vendor":"Acme","type":"PLA","name":"Acme PLA","rank":"first"}`, nil)}, []string{"invalid-notes"}},
+		{"unresolved-parent", []string{profile(acmeNotes, map[string]string{"inherits": `"Acme Base"`})}, []string{"unresolved-parent"}},
+		{"missing-temperature", []string{profile(acmeNotes, map[string]string{"hot_plate_temp": ""})}, []string{"missing-temperature"}},
+		{"invalid-number", []string{profile(acmeNotes, map[string]string{"filament_density": `["nil"]`})}, []string{"invalid-number"}},
+		{"type-mismatch", []string{profile(acmeNotes, map[string]string{"filament_type": `["PETG"]`})}, []string{"type-mismatch"}},
+		{"unknown-type", []string{profile(`{"id":"90001","vendor":"Acme","type":"UNOBTAINIUM","name":"Acme PLA"}`, map[string]string{"filament_type": `["UNOBTAINIUM"]`})}, []string{"unknown-type"}},
+		{
+			"duplicate-id",
+			[]string{profile(acmeNotes, nil), profile(`{"id":"90001","vendor":"Acme","type":"PLA","name":"Acme PLA Silk"}`, nil)},
+			[]string{"duplicate-id"},
+		},
+		{
+			"duplicate-name",
+			[]string{profile(acmeNotes, nil), profile(`{"id":"90002","vendor":"ACME","type":"PLA","name":"acme pla"}`, nil)},
+			[]string{"duplicate-name"},
+		},
+		{"dropped-settings", []string{profile(acmeNotes, map[string]string{"filament_shrink": `["100%"]`})}, []string{"dropped-settings"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var paths []string
+			for i, content := range tt.files {
+				path := filepath.Join(dir, string(rune('a'+i))+".json")
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+			}
+
+			findings := newChecker(t, dir).Check(paths)
+			var got []string
+			for _, finding := range findings {
+				got = append(got, finding.Rule)
+				if finding.Level != ruleLevel(finding.Rule) {
+					t.Errorf("%s finding has level %s", finding.Rule, finding.Level)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() rules = %v, want %v; findings: %+v", got, tt.want, findings)
+			}
+		})
+	}
+}
+
+// goldenFindings is one finding of each level, with a path that needs slash conversion in SARIF.
+var goldenFindings = []Finding{
+	{Rule: "invalid-number", Level: LevelError, File: "profiles/Acme PLA.json", Message: `filament_density is "nil", expected a number`},
+	{Rule: "dropped-settings", Level: LevelWarning, File: "profiles/Acme PLA.json", Message: "not synced: filament_shrink"},
+}
+
+func TestWriteGolden(t *testing.T) {
+	tests := []struct {
+		format   string
+		findings []Finding
+		golden   string
+	}{
+		{FormatJSON, goldenFindings, "findings.json"},
+		{FormatJSON, nil, "empty.json"},
+		{FormatSARIF, goldenFindings, "findings.sarif"},
+		{FormatText, goldenFindings, "findings.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Write(&out, tt.format, tt.findings, 3); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("%s output differs from %s:\n%s", tt.format, golden, out.String())
+			}
+		})
+	}
+}
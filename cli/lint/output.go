@@ -0,0 +1,133 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Output formats accepted by --format.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// Write prints the findings in the given format.
+func Write(w io.Writer, format string, findings []Finding, checked int) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, findings, checked)
+	case FormatSARIF:
+		return writeSARIF(w, findings)
+	default:
+		return writeText(w, findings, checked)
+	}
+}
+
+// writeText prints one line per finding, compiler style, and a summary.
+func writeText(w io.Writer, findings []Finding, checked int) error {
+	errorCount := 0
+	for _, finding := range findings {
+		if finding.Level == LevelError {
+			errorCount++
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s [%s] %s\n", finding.File, finding.Level, finding.Rule, finding.Message); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d files checked: %d errors, %d warnings\n", checked, errorCount, len(findings)-errorCount)
+	return err
+}
+
+// writeJSON prints the findings as a JSON document.
+func writeJSON(w io.Writer, findings []Finding, checked int) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Checked  int       `json:"checked"`
+		Findings []Finding `json:"findings"`
+	}{checked, findings})
+}
+
+// SARIF 2.1.0, the subset code scanning services read.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level Level `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     Level           `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF prints the findings as a SARIF log for CI code scanning.
+func writeSARIF(w io.Writer, findings []Finding) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "filament-sync-tool"
+	run.Tool.Driver.InformationURI = "https://github.com/zaggash/go-filament-sync"
+	for _, rule := range Rules {
+		sarif := sarifRule{ID: rule.ID, ShortDescription: sarifMessage{Text: rule.Description}}
+		sarif.DefaultConfiguration.Level = rule.Level
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarif)
+	}
+
+	for _, finding := range findings {
+		var location sarifLocation
+		location.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(finding.File)
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    finding.Rule,
+			Level:     finding.Level,
+			Message:   sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"filament-sync-tool/cli/profiles"
+)
+
+// runProfiles dispatches the "profiles" subcommands.
+func runProfiles() {
+	subcommand := ""
+	if len(appConfig.Args) > 0 {
+		subcommand = appConfig.Args[0]
+	}
+
+	switch subcommand {
+	case "locate":
+		locateProfiles()
+	default:
+		log.Fatalf("Unknown profiles subcommand %q, expected \"locate\"", subcommand)
+	}
+}
+
+// locateProfiles prints every slicer profile directory found on this computer. The one
+// --profile-path auto would use is marked with "*".
+func locateProfiles() {
+	candidates := profiles.DiscoverProfileDirs(profiles.DefaultDiscoveryRoots())
+	if len(candidates) == 0 {
+		fmt.Println("No OrcaSlicer or Creality Print profile directory found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AUTO\tSLICER\tVERSION\tUSER\tFLATPAK\tMODIFIED\tPATH")
+	for i, candidate := range candidates {
+		marker := ""
+		if i == 0 {
+			marker = "*"
+		}
+		version := candidate.Version
+		if version == "" {
+			version = "-"
+		}
+		flatpak := "no"
+		if candidate.Flatpak {
+			flatpak = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", marker, candidate.Slicer, version, candidate.UserID, flatpak,
+			candidate.Modified.Format("2006-01-02 15:04"), candidate.Path)
+	}
+	w.Flush()
+}
@@ -3,21 +3,24 @@ package main
 import (
 	"bytes"
 	"embed"
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
 	"filament-sync-tool/cli/config"   // Import our new config package
 	"filament-sync-tool/cli/creality" // Import our new creality package
-	"filament-sync-tool/cli/profiles"  // Import our new profiles package
+	"filament-sync-tool/cli/printer"  // Import our new printer package
+	"filament-sync-tool/cli/profiles" // Import our new profiles package
 	"filament-sync-tool/cli/scp"      // Import our new scp package
 )
 
 // Global variable to hold parsed config, populated by config.LoadConfig()
 var appConfig *config.ToolConfig
 
+// printerModel is the registry entry for --model; its BoxDir is the remote path on the printer
+var printerModel *printer.Model
+
 // Global variables for in-memory databases
 var (
 	materialDB      *creality.MaterialDatabase
@@ -36,6 +39,13 @@ func init() {
 	// Load configuration from command-line arguments
 	appConfig = config.LoadConfig()
 
+	// The model was validated by config.LoadConfig
+	var err error
+	printerModel, err = printer.LookupModel(appConfig.Model)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	// Read default JSONs from embedded filesystem
 	sourceDBData, err := embeddedData.ReadFile("data/material_database.json") // embed.FS uses ReadFile directly
 	if err != nil {
@@ -61,108 +71,296 @@ func init() {
 }
 
 func main() {
-	// printerTargetDir is the remote path on the printer
-	printerTargetDir := "/mnt/UDISK/creality/userdata/box"
+	switch appConfig.Command {
+	case "status":
+		runStatus()
+	case "factory-diff":
+		runFactoryDiff()
+	case "factory-reset":
+		runFactoryReset()
+	case "baseline":
+		runBaseline()
+	case "profiles":
+		runProfiles()
+	case "ids":
+		runIDs()
+	case "lint":
+		runLint()
+	default:
+		runSync()
+	}
+}
 
-	// Use user-supplied profile directory (validated in config.LoadConfig)
-	profileDir := appConfig.ProfilePath
-	log.Printf("Scanning for profiles in: %s", profileDir)
+// localProfile is a custom slicer profile converted into a Creality database entry.
+type localProfile struct {
+	Path      string
+	Notes     *profiles.FilamentNotes
+	Settings  map[string]string                // The normalized slicer settings
+	Defaulted map[string]bool                  // Settings taken from the built-in defaults
+	Entry     *creality.FilamentProfileEntry   // The converted profile, covering all its nozzle diameters
+	Entries   []*creality.FilamentProfileEntry // What is written to the database: Entry, or one entry per diameter
+}
 
-	slicerProfilePaths, err := profiles.LoadCustomProfiles(profileDir)
+// loadLocalProfiles reads the profiles from --from-3mf, --from-gcode or the profile directories and
+// applies the profile filter. Profiles that fail to read are logged and skipped. Profiles whose
+// ID is already provided by an earlier one are returned as duplicates. The selected profiles are
+// not converted yet: their stock templates come from the baseline, see convertLocalProfiles.
+func loadLocalProfiles() ([]localProfile, []profiles.Duplicate) {
+	// Build the profile filter first so an invalid rule fails before anything is read
+	profileFilter, err := profiles.NewFilter(appConfig.Include, appConfig.Exclude)
 	if err != nil {
-		log.Fatalf("Error loading custom profiles from %s: %v", profileDir, err)
+		log.Fatalf("Invalid profile filter: %v", err)
 	}
 
-	if len(slicerProfilePaths) == 0 {
-		log.Printf("No custom filament profiles found in: %s", profileDir)
-		log.Println("Ensure your profiles have 'filament_notes' as described in the README:")
-		log.Println("https://github.com/zaggash/go-filament-sync#creating-custom-filament-presets")
-		return // Exit if no profiles to sync
+	identity := loadIdentity()
+
+	var slicerProfiles []*profiles.SlicerFilamentProfile
+	var duplicates []profiles.Duplicate
+	switch {
+	case appConfig.From3MF != "":
+		slicerProfiles = readProjectProfiles(appConfig.From3MF)
+	case appConfig.FromGCode != "":
+		slicerProfiles = readGCodeProfiles(appConfig.FromGCode)
+	default:
+		slicerProfiles, duplicates = readProfileDirs(identity)
 	}
 
-	log.Printf("Found %d custom profiles. Processing and preparing for transfer...", len(slicerProfilePaths))
+	// Profiles declare the printers and nozzles they are for the way the slicer checks them
+	target := profiles.TargetPrinter{Model: printerModel.DisplayName, Nozzles: printerModel.Nozzles, AllowUnevaluated: appConfig.AllowUneval}
+	if appConfig.Nozzle != "" {
+		target.Nozzles = []string{appConfig.Nozzle}
+	}
 
 	// Process each custom profile
-	for _, path := range slicerProfilePaths {
+	var loaded []localProfile
+	providedBy := make(map[string]string)
+	for _, slicerProfile := range slicerProfiles {
+		path := slicerProfile.Path
 		log.Printf("Processing profile: %s", path)
-		slicerProfile, err := profiles.ReadSlicerProfile(path)
+
+		normalizedData, filamentNotes, err := profiles.NormalizeSlicerProfile(slicerProfile, identity)
 		if err != nil {
-			log.Printf("Skipping profile %s due to read error: %v", path, err)
+			log.Printf("Skipping profile %s due to normalization error: %v", path, err)
+			continue
+		}
+
+		// Directory scans drop duplicates while collecting; projects are checked here
+		if winner, ok := providedBy[filamentNotes.ID]; ok {
+			log.Printf("Ignoring %s: id %s is already provided by %s", path, filamentNotes.ID, winner)
+			duplicates = append(duplicates, profiles.Duplicate{ID: filamentNotes.ID, Path: path, Winner: winner})
+			continue
+		}
+		providedBy[filamentNotes.ID] = path
+
+		if selected, reason := profileFilter.Match(path, filamentNotes); !selected {
+			log.Printf("Skipping profile %s (id %s): %s", path, filamentNotes.ID, reason)
 			continue
 		}
 
-		normalizedData, filamentNotes, err := profiles.NormalizeSlicerProfile(slicerProfile)
+		if !filamentNotes.TargetsPrinter(printerModel.Name, printerModel.PrinterIntName, printerModel.DisplayName) {
+			log.Printf("Skipping profile %s (id %s): meant for %s, not %s", path, filamentNotes.ID, strings.Join(filamentNotes.Printers, ", "), printerModel.Name)
+			continue
+		}
+
+		compatible, reason, warning := target.Compatible(slicerProfile.RawData)
+		if warning != "" {
+			log.Printf("Warning: %s: %s", path, warning)
+		}
+		if !compatible {
+			log.Printf("Skipping profile %s (id %s): not compatible with %s: %s", path, filamentNotes.ID, printerModel.DisplayName, reason)
+			continue
+		}
+
+		loaded = append(loaded, localProfile{Path: path, Notes: filamentNotes, Settings: normalizedData, Defaulted: slicerProfile.Defaulted})
+	}
+	return loaded, duplicates
+}
+
+// convertLocalProfiles converts the profiles into Creality database entries, using stock
+// filaments of the loaded baseline as templates. Call it after loadBaseline. Profiles that fail
+// to convert are logged and skipped.
+func convertLocalProfiles(localProfiles []localProfile, fieldMapping *creality.Mapping) []localProfile {
+	var converted []localProfile
+	for _, profile := range localProfiles {
+		path := profile.Path
+
+		// A stock filament of the same type fills in what the profile does not set
+		template, err := creality.FindTemplate(materialDB, profile.Settings, profile.Notes)
 		if err != nil {
-			log.Printf("Skipping profile %s due to normalization error: %v", path, err)
+			log.Printf("Skipping profile %s due to conversion error: %v", path, err)
 			continue
 		}
+		if template != nil {
+			log.Printf("Using stock filament %s (%s %s) as the template for %s", template.Base.ID, template.Base.Brand, template.Base.Name, path)
+			// The built-in defaults only stand in for settings no template provides
+			for key := range profile.Defaulted {
+				delete(profile.Settings, key)
+			}
+		}
 
-		crealityEntry, err := creality.ConvertToCrealityFormat(normalizedData, filamentNotes)
+		crealityEntry, err := creality.ConvertToCrealityFormat(profile.Settings, profile.Notes, fieldMapping, template)
 		if err != nil {
 			log.Printf("Skipping profile %s due to conversion error: %v", path, err)
 			continue
 		}
 
-		// Update in-memory databases with the new/updated entry
-		currentTimestamp := fmt.Sprintf("%d", time.Now().Unix())
-		creality.AddProfileToDatabase(materialDB, crealityEntry, currentTimestamp)
-		creality.UpdateOptions(materialOptions, filamentNotes)
+		entries, err := creality.ExpandNozzleDiameters(crealityEntry, profile.Notes.NozzleOverrides)
+		if err != nil {
+			log.Printf("Skipping profile %s due to conversion error: %v", path, err)
+			continue
+		}
+
+		profile.Entry = crealityEntry
+		profile.Entries = entries
+		converted = append(converted, profile)
 	}
+	return converted
+}
 
-	// --- Prepare Data for SCP (from memory) ---
-	updatedDBBytes, err := creality.MarshalDatabase(materialDB)
+// loadIdentity sets up where profile IDs come from, from --identity and the ID map.
+func loadIdentity() *profiles.Identity {
+	// The identity mode was validated in config.LoadConfig; an explicit --id-map must exist
+	identityMode, _ := profiles.ParseIdentityMode(appConfig.Identity)
+	idMapPath := appConfig.IDMap
+	if idMapPath == "" {
+		idMapPath = filepath.Join(appConfig.DataDir, profiles.IDMapFile)
+	}
+	identity, err := profiles.NewIdentity(identityMode, idMapPath, appConfig.IDMap != "")
 	if err != nil {
-		log.Fatalf("Failed to marshal updated material database to bytes: %v", err)
+		log.Fatalf("Invalid ID map: %v", err)
+	}
+	if len(identity.Pins) > 0 {
+		log.Printf("Loaded %d pinned IDs from %s", len(identity.Pins), idMapPath)
 	}
+	return identity
+}
 
-	updatedOptBytes, err := creality.MarshalOptions(materialOptions)
+// loadMapping returns the rules that turn slicer settings into database fields: the embedded
+// ones, overridden by --mapping or the mapping file in the data directory.
+func loadMapping() *creality.Mapping {
+	mappingPath := appConfig.Mapping
+	if mappingPath == "" {
+		mappingPath = filepath.Join(appConfig.DataDir, creality.MappingFile)
+	}
+	mapping, loaded, err := creality.LoadMapping(mappingPath, appConfig.Mapping != "")
 	if err != nil {
-		log.Fatalf("Failed to marshal updated material options to bytes: %v", err)
+		log.Fatalf("Invalid field mapping: %v", err)
+	}
+	if loaded {
+		log.Printf("Loaded field mapping overrides from %s", mappingPath)
 	}
+	return mapping
+}
 
-	// --- SCP Transfer to Printer ---
-	log.Println("Initiating SCP transfer to printer (from memory)...")
-	scpClient, err := scp.NewSCPClient(appConfig.PrinterIP, appConfig.User, appConfig.Password)
+// readProfileDirs reads the profiles that carry an ID in the profile directories, with their
+// inherited settings resolved.
+func readProfileDirs(identity *profiles.Identity) ([]*profiles.SlicerFilamentProfile, []profiles.Duplicate) {
+	// Use user-supplied profile directories (validated in config.LoadConfig)
+	profileDirs := appConfig.ProfilePaths
+	log.Printf("Scanning for profiles in: %s", strings.Join(profileDirs, ", "))
+
+	slicerProfilePaths, duplicates, err := profiles.CollectProfiles(profileDirs, appConfig.Recursive, identity)
 	if err != nil {
-		log.Fatalf("Failed to initialize SCP client: %v", err)
+		log.Fatalf("Error loading custom profiles: %v", err)
+	}
+	for _, duplicate := range duplicates {
+		log.Printf("Ignoring %s: id %s is already provided by %s", duplicate.Path, duplicate.ID, duplicate.Winner)
 	}
 
-	// Establish and defer close SSH connection once for transfer operations
-	if err := scpClient.Connect(); err != nil {
-		log.Fatalf("Failed to establish SSH connection to printer: %v", err)
+	if len(slicerProfilePaths) == 0 {
+		log.Printf("No custom filament profiles found in: %s", strings.Join(profileDirs, ", "))
+		log.Println("Ensure your profiles have 'filament_notes' as described in the README:")
+		log.Println("https://github.com/zaggash/go-filament-sync#creating-custom-filament-presets")
+		log.Println("or use --identity preset to sync them by their slicer preset name instead.")
+		return nil, duplicates
 	}
-	defer scpClient.Close()
 
-	// Check if the remote directory exists
-	_, err = scpClient.CheckRemoteDirectory(printerTargetDir)
-	if err != nil {
-		log.Fatalf("Error checking remote directory %s: %v", printerTargetDir, err)
+	log.Printf("Found %d custom profiles. Processing...", len(slicerProfilePaths))
+
+	resolver := newPresetResolver(profileDirs, slicerProfilePaths)
+
+	var slicerProfiles []*profiles.SlicerFilamentProfile
+	for _, path := range slicerProfilePaths {
+		slicerProfile, err := profiles.ReadSlicerProfile(path)
+		if err != nil {
+			log.Printf("Skipping profile %s due to read error: %v", path, err)
+			continue
+		}
+
+		if err := resolver.Resolve(slicerProfile); err != nil {
+			log.Printf("Warning: profile %s is only partly resolved: %v", path, err)
+		}
+		slicerProfiles = append(slicerProfiles, slicerProfile)
 	}
+	return slicerProfiles, duplicates
+}
 
-	// Upload material_database.json directly from bytes
-	dbReader := bytes.NewReader(updatedDBBytes)
-	dbFileName := "material_database.json" // Hardcoded filename for remote upload
-	dbFileSize := int64(len(updatedDBBytes))
-	dbFileMode := os.FileMode(0644) // Default permissions for the file on the printer
+// readProjectProfiles reads the filament presets embedded in a 3MF project. The project holds
+// their complete settings, so there is nothing to resolve.
+func readProjectProfiles(path string) []*profiles.SlicerFilamentProfile {
+	log.Printf("Reading filament presets from project: %s", path)
+	slicerProfiles, err := profiles.ReadProjectProfiles(path)
+	if err != nil {
+		log.Fatalf("Error loading project profiles: %v", err)
+	}
+	log.Printf("Found %d filament presets in the project. Processing...", len(slicerProfiles))
+	return slicerProfiles
+}
 
-	remoteDBPath := filepath.Join(printerTargetDir, dbFileName)
-	if err := scpClient.UploadFile(dbReader, remoteDBPath, dbFileName, dbFileSize, dbFileMode); err != nil {
-		log.Fatalf("Failed to upload %s to printer: %v", dbFileName, err)
+// readGCodeProfiles reads the filament presets a G-code file was sliced with.
+func readGCodeProfiles(path string) []*profiles.SlicerFilamentProfile {
+	log.Printf("Reading filament presets from G-code: %s", path)
+	slicerProfiles, err := profiles.ReadGCodeProfiles(path)
+	if err != nil {
+		log.Fatalf("Error loading G-code profiles: %v", err)
 	}
-	log.Printf("Uploaded %s to printer.", dbFileName)
+	log.Printf("Found %d filament presets used by the G-code. Processing...", len(slicerProfiles))
+	return slicerProfiles
+}
 
-	// Upload material_option.json directly from bytes
-	optReader := bytes.NewReader(updatedOptBytes)
-	optFileName := "material_option.json" // Hardcoded filename for remote upload
-	optFileSize := int64(len(updatedOptBytes))
-	optFileMode := os.FileMode(0644) // Default permissions for the file on the printer
+// newPresetResolver indexes the parent presets of the given profiles. User presets usually
+// only store what differs from their parent; the resolver fills in the rest. Parents may live
+// next to any source or in its parent (filament/ next to filament/base).
+func newPresetResolver(profileDirs, profilePaths []string) *profiles.Resolver {
+	systemDir := appConfig.SystemDir
+	if systemDir == "" {
+		systemDir = profiles.DefaultSystemDir(profileDirs[0])
+	}
+	if systemDir != "" {
+		log.Printf("Resolving inherited settings with system profiles from: %s", systemDir)
+	} else {
+		log.Println("No slicer system profile directory found; inherited settings fall back to defaults. Use --system-profiles to set one.")
+	}
+	var presetDirs []string
+	for _, dir := range profileDirs {
+		presetDirs = append(presetDirs, dir, filepath.Dir(dir))
+	}
+	for _, path := range profilePaths {
+		presetDirs = append(presetDirs, filepath.Dir(path)) // Sub-directories found by --recursive
+	}
+	resolver, err := profiles.NewResolver(presetDirs, systemDir)
+	if err != nil {
+		log.Fatalf("Failed to index parent presets: %v", err)
+	}
+	return resolver
+}
 
-	remoteOptionsPath := filepath.Join(printerTargetDir, optFileName)
-	if err := scpClient.UploadFile(optReader, remoteOptionsPath, optFileName, optFileSize, optFileMode); err != nil {
-		log.Fatalf("Failed to upload %s to printer: %v", optFileName, err)
+// connectPrinter opens the SSH connection to the printer; the caller must Close it.
+func connectPrinter() *scp.SCPClient {
+	scpClient, err := scp.NewSCPClient(appConfig.PrinterIP, appConfig.User, appConfig.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize SCP client: %v", err)
 	}
-	log.Printf("Uploaded %s to printer.", optFileName)
 
-	log.Println("Filament profiles synchronized successfully with the printer!")
+	if err := scpClient.Connect(); err != nil {
+		log.Fatalf("Failed to establish SSH connection to printer: %v", err)
+	}
+	return scpClient
 }
 
+// uploadToBox uploads data as fileName into the model's box directory on the printer.
+func uploadToBox(scpClient *scp.SCPClient, fileName string, data []byte) error {
+	fileMode := os.FileMode(0644) // Default permissions for the file on the printer
+	remotePath := filepath.Join(printerModel.BoxDir, fileName)
+	return scpClient.UploadFile(bytes.NewReader(data), remotePath, fileName, int64(len(data)), fileMode)
+}
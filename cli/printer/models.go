@@ -0,0 +1,104 @@
+package printer
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// DefaultModel is the registry key used when --model is not given.
+const DefaultModel = "k2plus"
+
+//go:embed snapshots
+var snapshotFS embed.FS // Factory copies of the box directory, one sub-directory per model
+
+// Model describes a supported printer model.
+type Model struct {
+	Name           string   // Registry key used with --model
+	DisplayName    string   // Human-readable model name
+	PrinterIntName string   // printerIntName used by the model's material database entries
+	BoxDir         string   // Remote directory holding the CFS material files
+	SnapshotDir    string   // Factory snapshot of BoxDir inside snapshotFS, empty if none is bundled
+	FirmwareFile   string   // Remote file holding the firmware version
+	Nozzles        []string // Nozzle diameters the model can be fitted with, in millimetres
+}
+
+// models is the registry of supported printer models. Add an entry (and a snapshot under
+// snapshots/) to support another model.
+var models = map[string]*Model{
+	"k2plus": {
+		Name:           "k2plus",
+		DisplayName:    "Creality K2 Plus",
+		PrinterIntName: "F008",
+		BoxDir:         "/mnt/UDISK/creality/userdata/box",
+		SnapshotDir:    "snapshots/k2plus-default/box",
+		FirmwareFile:   "/etc/openwrt_version",
+		Nozzles:        []string{"0.2", "0.4", "0.6", "0.8"},
+	},
+}
+
+// LookupModel returns the registered model with the given name.
+func LookupModel(name string) (*Model, error) {
+	model, ok := models[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown printer model %q, supported models: %s", name, strings.Join(ModelNames(), ", "))
+	}
+	return model, nil
+}
+
+// ModelNames returns the registry keys of all supported models, sorted.
+func ModelNames() []string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snapshot returns the model's bundled factory snapshot of the box directory.
+func (m *Model) Snapshot() (fs.FS, error) {
+	if m.SnapshotDir == "" {
+		return nil, fmt.Errorf("no factory snapshot is bundled for %s", m.DisplayName)
+	}
+	return fs.Sub(snapshotFS, m.SnapshotDir)
+}
+
+// FirmwareVersion reads the firmware version from the printer.
+func (m *Model) FirmwareVersion(runner CommandRunner) (string, error) {
+	if m.FirmwareFile == "" {
+		return "", fmt.Errorf("firmware version detection is not supported for %s", m.DisplayName)
+	}
+	output, err := runner.RunCommand(fmt.Sprintf("cat '%s'", m.FirmwareFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read firmware version: %w", err)
+	}
+	version := strings.TrimSpace(string(output))
+	if version == "" {
+		return "", fmt.Errorf("firmware version file %s is empty", m.FirmwareFile)
+	}
+	return version, nil
+}
+
+// SnapshotFiles lists the file names in the model's factory snapshot, sorted.
+func (m *Model) SnapshotFiles() ([]string, error) {
+	snapshot, err := m.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(snapshot, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list factory snapshot for %s: %w", m.DisplayName, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,108 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PrintState is the job state reported by the printer's Moonraker status API (print_stats.state).
+type PrintState string
+
+const (
+	StateStandby   PrintState = "standby"
+	StatePrinting  PrintState = "printing"
+	StatePaused    PrintState = "paused"
+	StateComplete  PrintState = "complete"
+	StateCancelled PrintState = "cancelled"
+	StateError     PrintState = "error"
+)
+
+// DefaultStatusPort is the port Moonraker listens on for the local status API.
+const DefaultStatusPort = 7125
+
+// printStatsQuery is the Moonraker endpoint returning the current job state.
+const printStatsQuery = "/printer/objects/query?print_stats"
+
+// CommandRunner runs a shell command on the printer and returns its standard output.
+// It is satisfied by *scp.SCPClient.
+type CommandRunner interface {
+	RunCommand(cmd string) ([]byte, error)
+}
+
+// Busy reports whether a job is active, i.e. material data is in use and must not be overwritten.
+func (s PrintState) Busy() bool {
+	return s == StatePrinting || s == StatePaused
+}
+
+// Idle reports whether no job is active, i.e. the database can be written. A state the tool
+// does not know is not idle.
+func (s PrintState) Idle() bool {
+	switch s {
+	case StateStandby, StateComplete, StateCancelled, StateError:
+		return true
+	}
+	return false
+}
+
+// StatusURL returns the default status API base URL for the given printer host.
+func StatusURL(host string) string {
+	return fmt.Sprintf("http://%s:%d", host, DefaultStatusPort)
+}
+
+// ParsePrintStats extracts the job state from a Moonraker print_stats query response.
+func ParsePrintStats(data []byte) (PrintState, error) {
+	var response struct {
+		Result struct {
+			Status struct {
+				PrintStats struct {
+					State string `json:"state"`
+				} `json:"print_stats"`
+			} `json:"status"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return "", fmt.Errorf("failed to parse print_stats response: %w", err)
+	}
+
+	state := strings.TrimSpace(response.Result.Status.PrintStats.State)
+	if state == "" {
+		return "", fmt.Errorf("print_stats response does not contain a state")
+	}
+	return PrintState(state), nil
+}
+
+// QueryPrintState asks the printer's status API at baseURL for the current job state.
+func QueryPrintState(baseURL string, timeout time.Duration) (PrintState, error) {
+	client := &http.Client{Timeout: timeout}
+
+	resp, err := client.Get(strings.TrimSuffix(baseURL, "/") + printStatsQuery)
+	if err != nil {
+		return "", fmt.Errorf("failed to query status API at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status API at %s returned %s", baseURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read status API response: %w", err)
+	}
+	return ParsePrintStats(body)
+}
+
+// ProbePrintState queries the status API from the printer itself over SSH.
+// This works when the API only listens on localhost or is firewalled from the network.
+func ProbePrintState(runner CommandRunner) (PrintState, error) {
+	cmd := fmt.Sprintf("wget -q -O - 'http://127.0.0.1:%d%s'", DefaultStatusPort, printStatsQuery)
+	output, err := runner.RunCommand(cmd)
+	if err != nil {
+		return "", fmt.Errorf("remote status probe failed: %w", err)
+	}
+	return ParsePrintStats(output)
+}
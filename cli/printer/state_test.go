@@ -0,0 +1,87 @@
+package printer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueryPrintState(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    PrintState
+		wantErr bool
+		idle    bool
+	}{
+		{name: "printing", status: http.StatusOK, body: `{"result":{"status":{"print_stats":{"state":"printing"}}}}`, want: StatePrinting},
+		{name: "paused", status: http.StatusOK, body: `{"result":{"status":{"print_stats":{"state":"paused"}}}}`, want: StatePaused},
+		{name: "standby", status: http.StatusOK, body: `{"result":{"status":{"print_stats":{"state":"standby"}}}}`, want: StateStandby, idle: true},
+		{name: "unknown state", status: http.StatusOK, body: `{"result":{"status":{"print_stats":{"state":"calibrating"}}}}`, want: "calibrating"},
+		{name: "not found", status: http.StatusNotFound, body: `{"error":"not found"}`, wantErr: true},
+		{name: "malformed JSON", status: http.StatusOK, body: `{"result":{"status":`, wantErr: true},
+		{name: "missing state", status: http.StatusOK, body: `{"result":{"status":{}}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/printer/objects/query" || !r.URL.Query().Has("print_stats") {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			state, err := QueryPrintState(server.URL, time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("QueryPrintState() = %q, want an error", state)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QueryPrintState() error: %v", err)
+			}
+			if state != tt.want {
+				t.Errorf("QueryPrintState() = %q, want %q", state, tt.want)
+			}
+			if state.Idle() != tt.idle {
+				t.Errorf("%q.Idle() = %t, want %t", state, state.Idle(), tt.idle)
+			}
+		})
+	}
+}
+
+func TestQueryPrintStateUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	if state, err := QueryPrintState(url, time.Second); err == nil {
+		t.Fatalf("QueryPrintState() = %q for a closed server, want an error", state)
+	}
+}
+
+type fakeRunner struct {
+	output []byte
+	err    error
+}
+
+func (f fakeRunner) RunCommand(cmd string) ([]byte, error) {
+	return f.output, f.err
+}
+
+func TestProbePrintState(t *testing.T) {
+	state, err := ProbePrintState(fakeRunner{output: []byte(`{"result":{"status":{"print_stats":{"state":"paused"}}}}`)})
+	if err != nil || state != StatePaused || state.Idle() {
+		t.Errorf("ProbePrintState() = %q, %v, want paused", state, err)
+	}
+	if _, err := ProbePrintState(fakeRunner{output: []byte("wget: server returned error: HTTP/1.1 404 Not Found")}); err == nil {
+		t.Error("ProbePrintState() accepted a non-JSON response")
+	}
+}
@@ -0,0 +1,559 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errUnknownVariable means a condition uses a printer setting the tool does not know, so its
+// result cannot be decided.
+var errUnknownVariable = errors.New("unknown variable")
+
+// TargetPrinter describes the printer profiles are synced to, in the terms slicer presets use
+// to declare compatibility.
+type TargetPrinter struct {
+	Model   string   // Slicer printer_model, e.g. "Creality K2 Plus"
+	Nozzles []string // Nozzle diameters that may be installed, e.g. "0.4"
+	// AllowUnevaluated counts conditions the tool cannot evaluate as compatible, with a warning
+	AllowUnevaluated bool
+}
+
+// Compatible reports whether a resolved preset may be used on the target printer with at least
+// one of its nozzles, and why not otherwise. As in the slicer, a non-empty compatible_printers
+// list decides on its own; compatible_printers_condition only applies when the list is empty.
+// A condition the tool cannot evaluate, because it does not parse or uses a printer setting the
+// tool does not know, rules the preset out unless AllowUnevaluated is set; warning then
+// explains why it was accepted.
+func (t TargetPrinter) Compatible(raw map[string]interface{}) (ok bool, reason string, warning string) {
+	if printers := stringList(raw["compatible_printers"]); len(printers) > 0 {
+		for _, name := range printers {
+			for _, nozzle := range t.Nozzles {
+				if t.matchesPreset(name, nozzle) {
+					return true, "", ""
+				}
+			}
+		}
+		return false, fmt.Sprintf("compatible_printers lists only %s", strings.Join(printers, ", ")), ""
+	}
+
+	condition := firstString(raw["compatible_printers_condition"])
+	if condition == "" {
+		return true, "", ""
+	}
+	for _, nozzle := range t.Nozzles {
+		result, err := evaluateCondition(condition, t.variables(nozzle))
+		if err != nil {
+			problem := fmt.Sprintf("cannot evaluate compatible_printers_condition %q (%v)", condition, err)
+			if t.AllowUnevaluated {
+				return true, "", problem + "; syncing anyway"
+			}
+			return false, problem, ""
+		}
+		if result {
+			return true, "", ""
+		}
+	}
+	return false, fmt.Sprintf("compatible_printers_condition %q is false for %s with a %s mm nozzle", condition, t.Model, strings.Join(t.Nozzles, "/")), ""
+}
+
+// matchesPreset reports whether a printer preset name such as "Creality K2 Plus 0.4 nozzle"
+// names the target model with the given nozzle. Names without a nozzle match any nozzle.
+func (t TargetPrinter) matchesPreset(name, nozzle string) bool {
+	name = strings.TrimSpace(name)
+	if len(name) < len(t.Model) || !strings.EqualFold(name[:len(t.Model)], t.Model) {
+		return false
+	}
+	rest := strings.TrimSpace(name[len(t.Model):])
+	if rest == "" {
+		return true
+	}
+	// "Creality K2 Plus 0.4 nozzle", but not "Creality K2 Plus Combo 0.4 nozzle"
+	diameter, _, found := strings.Cut(rest, " ")
+	if !found || !strings.HasPrefix(strings.ToLower(rest[len(diameter):]), " nozzle") {
+		return false
+	}
+	a, errA := strconv.ParseFloat(diameter, 64)
+	b, errB := strconv.ParseFloat(nozzle, 64)
+	return errA == nil && errB == nil && a == b
+}
+
+// variables returns the printer settings conditions may refer to, for one nozzle.
+func (t TargetPrinter) variables(nozzle string) map[string][]string {
+	return map[string][]string{
+		"printer_model":       {t.Model},
+		"printer_settings_id": {t.Model + " " + nozzle + " nozzle"},
+		"printer_variant":     {nozzle},
+		"nozzle_diameter":     {nozzle},
+		"printer_technology":  {"FFF"},
+		"num_extruders":       {"1"}, // The CFS feeds a single extruder
+		"printer_notes":       {""},  // Creality printer presets carry no notes
+	}
+}
+
+// stringList returns the strings of an array value, or a one-element list for a non-empty
+// string.
+func stringList(value interface{}) []string {
+	var list []string
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) != "" {
+			list = append(list, strings.TrimSpace(v))
+		}
+	case []interface{}:
+		for _, element := range v {
+			if s, ok := element.(string); ok && strings.TrimSpace(s) != "" {
+				list = append(list, strings.TrimSpace(s))
+			}
+		}
+	}
+	return list
+}
+
+// evaluateCondition evaluates a slicer compatibility condition such as
+// `printer_model == "Creality K2 Plus" and nozzle_diameter[0] >= 0.4` against the given
+// variables. Supported are and, or, not (also &&, ||, !), parentheses, the comparisons
+// == != < > <= >=, regular expression matches =~ /re/ and !~ /re/, the arithmetic
+// operators + - * /, strings, numbers, true/false and indexed variables.
+func evaluateCondition(condition string, variables map[string][]string) (bool, error) {
+	result, err := evaluate(condition, variables)
+	if err != nil {
+		return false, err
+	}
+	return result.truth(), nil
+}
+
+// EvaluateExpression evaluates an expression in the condition syntax, such as
+// `filament_cost / 1000`, with each variable holding one value. A per-extruder vector of
+// numbers such as "0.02,0.03" is indexed like nozzle_diameter[0]; without an index its first
+// element is used. Numbers come out in their shortest form and booleans as "1" or "0", the
+// way slicer settings write them.
+func EvaluateExpression(expression string, variables map[string]string) (string, error) {
+	values := make(map[string][]string, len(variables))
+	for name, value := range variables {
+		values[name] = []string{value}
+		if elements := strings.Split(value, ","); len(elements) > 1 && allNumbers(elements) {
+			values[name] = elements
+		}
+	}
+	result, err := evaluate(expression, values)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case result.isBool && result.boolean:
+		return "1", nil
+	case result.isBool:
+		return "0", nil
+	case result.numeric:
+		return strconv.FormatFloat(result.number, 'f', -1, 64), nil
+	}
+	return result.text, nil
+}
+
+// allNumbers reports whether every element of texts is a number.
+func allNumbers(texts []string) bool {
+	for _, text := range texts {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckExpression reports syntax errors in an expression.
+func CheckExpression(expression string) error {
+	_, err := evaluate(expression, nil)
+	return err
+}
+
+// evaluate parses and evaluates an expression. Without variables it only checks the syntax.
+func evaluate(expression string, variables map[string][]string) (conditionValue, error) {
+	tokens, err := tokenizeCondition(expression)
+	if err != nil {
+		return conditionValue{}, err
+	}
+	parser := &conditionParser{tokens: tokens, variables: variables}
+	result, err := parser.or()
+	if err != nil {
+		return result, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return result, fmt.Errorf("unexpected %q", parser.tokens[parser.pos].text)
+	}
+	return result, nil
+}
+
+// conditionToken is a lexical element of a condition.
+type conditionToken struct {
+	kind byte // 'i' identifier, 's' string, 'n' number, 'r' regular expression, 'o' operator
+	text string
+}
+
+// tokenizeCondition splits a condition into tokens.
+func tokenizeCondition(condition string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	for i := 0; i < len(condition); {
+		c := condition[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			end := strings.IndexByte(condition[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, conditionToken{'s', condition[i+1 : i+1+end]})
+			i += end + 2
+		case c == '/' && len(tokens) > 0 && (tokens[len(tokens)-1].text == "=~" || tokens[len(tokens)-1].text == "!~"):
+			pattern, length, err := scanRegexp(condition[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, conditionToken{'r', pattern})
+			i += length
+		case unicode.IsDigit(rune(c)) || (c == '-' && i+1 < len(condition) && unicode.IsDigit(rune(condition[i+1])) && !followsValue(tokens)):
+			start := i
+			for i++; i < len(condition) && (unicode.IsDigit(rune(condition[i])) || condition[i] == '.'); i++ {
+			}
+			tokens = append(tokens, conditionToken{'n', condition[start:i]})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			// Dots let expressions name nested values such as filament_notes.type
+			for ; i < len(condition) && (condition[i] == '_' || condition[i] == '.' || unicode.IsLetter(rune(condition[i])) || unicode.IsDigit(rune(condition[i]))); i++ {
+			}
+			tokens = append(tokens, conditionToken{'i', condition[start:i]})
+		default:
+			operator := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "(", ")", "[", "]", "+", "-", "*", "/"} {
+				if strings.HasPrefix(condition[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, conditionToken{'o', operator})
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+// scanRegexp reads a /.../ regular expression literal at the start of text and returns the
+// pattern and the length of the literal. \/ stands for a slash; other escapes are left for
+// the pattern.
+func scanRegexp(text string) (string, int, error) {
+	var pattern strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '/':
+			return pattern.String(), i + 1, nil
+		case text[i] == '\\' && i+1 < len(text):
+			if text[i+1] != '/' {
+				pattern.WriteByte('\\')
+			}
+			i++
+			pattern.WriteByte(text[i])
+		default:
+			pattern.WriteByte(text[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated regular expression")
+}
+
+// followsValue reports whether the last token ends a value, so a following minus subtracts
+// rather than starting a negative number.
+func followsValue(tokens []conditionToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind != 'o' || last.text == ")" || last.text == "]"
+}
+
+// conditionValue is a string, number or boolean during evaluation.
+type conditionValue struct {
+	text    string
+	number  float64
+	numeric bool
+	boolean bool
+	isBool  bool
+}
+
+// truth is the value used by and, or, not and the final result.
+func (v conditionValue) truth() bool {
+	if v.isBool {
+		return v.boolean
+	}
+	if v.numeric {
+		return v.number != 0
+	}
+	return v.text != ""
+}
+
+// boolValue wraps a comparison result.
+func boolValue(b bool) conditionValue { return conditionValue{boolean: b, isBool: true} }
+
+// textValue wraps a string, treating numeric text as a number as the slicer does.
+func textValue(text string) conditionValue {
+	number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	return conditionValue{text: text, number: number, numeric: err == nil}
+}
+
+// conditionParser evaluates tokens by recursive descent.
+type conditionParser struct {
+	tokens    []conditionToken
+	pos       int
+	variables map[string][]string
+}
+
+// accept consumes the next token if it is one of the given keywords or operators.
+func (p *conditionParser) accept(texts ...string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	for _, text := range texts {
+		if p.tokens[p.pos].text == text && p.tokens[p.pos].kind != 's' {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *conditionParser) or() (conditionValue, error) {
+	left, err := p.and()
+	if err != nil {
+		return left, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.and()
+		if err != nil {
+			return right, err
+		}
+		left = boolValue(left.truth() || right.truth())
+	}
+	return left, nil
+}
+
+func (p *conditionParser) and() (conditionValue, error) {
+	left, err := p.not()
+	if err != nil {
+		return left, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.not()
+		if err != nil {
+			return right, err
+		}
+		left = boolValue(left.truth() && right.truth())
+	}
+	return left, nil
+}
+
+func (p *conditionParser) not() (conditionValue, error) {
+	if p.accept("not", "!") {
+		value, err := p.not()
+		return boolValue(!value.truth()), err
+	}
+	return p.comparison()
+}
+
+func (p *conditionParser) comparison() (conditionValue, error) {
+	left, err := p.sum()
+	if err != nil {
+		return left, err
+	}
+
+	if p.accept("=~", "!~") {
+		negate := p.tokens[p.pos-1].text == "!~"
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != 'r' {
+			return left, fmt.Errorf("expected a regular expression after %s", p.tokens[p.pos-1].text)
+		}
+		pattern, err := regexp.Compile("^(?:" + p.tokens[p.pos].text + ")$")
+		if err != nil {
+			return left, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		p.pos++
+		return boolValue(pattern.MatchString(left.text) != negate), nil
+	}
+
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(operator) {
+			continue
+		}
+		right, err := p.sum()
+		if err != nil {
+			return right, err
+		}
+		return boolValue(compareValues(left, right, operator)), nil
+	}
+	return left, nil
+}
+
+func (p *conditionParser) sum() (conditionValue, error) {
+	left, err := p.product()
+	if err != nil {
+		return left, err
+	}
+	for p.accept("+", "-") {
+		operator := p.tokens[p.pos-1].text
+		right, err := p.product()
+		if err != nil {
+			return right, err
+		}
+		if left, err = arithmetic(left, right, operator); err != nil {
+			return left, err
+		}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) product() (conditionValue, error) {
+	left, err := p.negation()
+	if err != nil {
+		return left, err
+	}
+	for p.accept("*", "/") {
+		operator := p.tokens[p.pos-1].text
+		right, err := p.negation()
+		if err != nil {
+			return right, err
+		}
+		if left, err = arithmetic(left, right, operator); err != nil {
+			return left, err
+		}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) negation() (conditionValue, error) {
+	if p.accept("-") {
+		value, err := p.negation()
+		if err != nil {
+			return value, err
+		}
+		return arithmetic(numberValue(0), value, "-")
+	}
+	return p.primary()
+}
+
+// arithmetic applies + - * / to two numbers.
+func arithmetic(left, right conditionValue, operator string) (conditionValue, error) {
+	if !left.numeric || !right.numeric {
+		return conditionValue{}, fmt.Errorf("%s needs numbers, got %q and %q", operator, left.text, right.text)
+	}
+	switch operator {
+	case "+":
+		return numberValue(left.number + right.number), nil
+	case "-":
+		return numberValue(left.number - right.number), nil
+	case "*":
+		return numberValue(left.number * right.number), nil
+	default:
+		if right.number == 0 {
+			return conditionValue{}, fmt.Errorf("division by zero")
+		}
+		return numberValue(left.number / right.number), nil
+	}
+}
+
+// numberValue wraps the result of a calculation.
+func numberValue(number float64) conditionValue {
+	return conditionValue{text: strconv.FormatFloat(number, 'f', -1, 64), number: number, numeric: true}
+}
+
+// compareValues compares numerically when both sides are numbers, as text otherwise.
+func compareValues(left, right conditionValue, operator string) bool {
+	var order int
+	switch {
+	case left.numeric && right.numeric:
+		switch {
+		case left.number < right.number:
+			order = -1
+		case left.number > right.number:
+			order = 1
+		}
+	case left.isBool || right.isBool:
+		if left.truth() != right.truth() {
+			order = 1
+		}
+	default:
+		order = strings.Compare(left.text, right.text)
+	}
+
+	switch operator {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case ">":
+		return order > 0
+	case "<=":
+		return order <= 0
+	default:
+		return order >= 0
+	}
+}
+
+func (p *conditionParser) primary() (conditionValue, error) {
+	if p.pos >= len(p.tokens) {
+		return conditionValue{}, fmt.Errorf("unexpected end of condition")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+
+	switch token.kind {
+	case 's':
+		return conditionValue{text: token.text}, nil
+	case 'n':
+		return textValue(token.text), nil
+	case 'i':
+		switch token.text {
+		case "true", "false":
+			return boolValue(token.text == "true"), nil
+		}
+		values, ok := p.variables[token.text]
+		if !ok && p.variables != nil {
+			return conditionValue{}, fmt.Errorf("%w %s", errUnknownVariable, token.text)
+		}
+		index := 0
+		if p.accept("[") {
+			if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != 'n' {
+				return conditionValue{}, fmt.Errorf("expected an index after %s[", token.text)
+			}
+			index, _ = strconv.Atoi(p.tokens[p.pos].text)
+			p.pos++
+			if !p.accept("]") {
+				return conditionValue{}, fmt.Errorf("expected ] after %s[%d", token.text, index)
+			}
+		}
+		if p.variables == nil {
+			return numberValue(1), nil // Syntax check: every variable is a number
+		}
+		// The target has one extruder; every index reads its value
+		if index < 0 || len(values) == 0 {
+			return conditionValue{}, fmt.Errorf("%s has no element %d", token.text, index)
+		}
+		return textValue(values[min(index, len(values)-1)]), nil
+	}
+
+	if token.text == "(" {
+		value, err := p.or()
+		if err != nil {
+			return value, err
+		}
+		if !p.accept(")") {
+			return value, fmt.Errorf("missing )")
+		}
+		return value, nil
+	}
+	return conditionValue{}, fmt.Errorf("unexpected %q", token.text)
+}
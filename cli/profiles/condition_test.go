@@ -0,0 +1,151 @@
+package profiles
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var k2Plus = TargetPrinter{Model: "Creality K2 Plus", Nozzles: []string{"0.4"}}
+
+func TestCompatiblePrintersList(t *testing.T) {
+	tests := []struct {
+		name     string
+		printers []interface{}
+		want     bool
+	}{
+		{"names the printer and nozzle", []interface{}{"Bambu Lab X1 Carbon 0.4 nozzle", "Creality K2 Plus 0.4 nozzle"}, true},
+		{"names the printer without nozzle", []interface{}{"Creality K2 Plus"}, true},
+		{"other nozzle", []interface{}{"Creality K2 Plus 0.6 nozzle"}, false},
+		{"same diameter written differently", []interface{}{"Creality K2 Plus 0.40 nozzle"}, true},
+		{"longer model name", []interface{}{"Creality K2 Plus Combo 0.4 nozzle"}, false},
+		{"other printers only", []interface{}{"Creality K1 Max 0.4 nozzle"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"compatible_printers": tt.printers,
+				// The list decides on its own when it is not empty
+				"compatible_printers_condition": `printer_model == "Nothing"`,
+			}
+			ok, reason, _ := k2Plus.Compatible(raw)
+			if ok != tt.want {
+				t.Errorf("Compatible() = %t (%s), want %t", ok, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompatiblePrintersCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{``, true},
+		{`printer_model == "Creality K2 Plus"`, true},
+		{`printer_model != "Creality K2 Plus"`, false},
+		{`printer_model == "Creality K2 Plus" and nozzle_diameter[0] >= 0.4`, true},
+		{`nozzle_diameter[0] == 0.6 || nozzle_diameter[0] == 0.8`, false},
+		{`printer_variant == "0.4"`, true},
+		{`printer_settings_id =~ /Creality K2 .* nozzle/`, true},
+		{`printer_settings_id =~ /.*Bambu.*/`, false},
+		{`printer_settings_id !~ /.*Bambu.*/`, true},
+		{`printer_notes=~/.*\/.*/`, false},
+		{`printer_model =~ /Creality K2 Plus|Creality K1/ && !(nozzle_diameter[0] > 0.4)`, true},
+		{`num_extruders == 1 and printer_technology == "FFF"`, true},
+		{`nozzle_diameter[0] * 2 == 0.8`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			ok, reason, warning := k2Plus.Compatible(map[string]interface{}{"compatible_printers_condition": tt.condition})
+			if ok != tt.want || warning != "" {
+				t.Errorf("Compatible() = %t (%s, warning %q), want %t", ok, reason, warning, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompatibleAnyNozzle(t *testing.T) {
+	target := TargetPrinter{Model: "Creality K2 Plus", Nozzles: []string{"0.2", "0.4", "0.6", "0.8"}}
+	raw := map[string]interface{}{"compatible_printers_condition": `nozzle_diameter[0] == 0.6`}
+	if ok, reason, _ := target.Compatible(raw); !ok {
+		t.Errorf("Compatible() = false (%s), want true for one of the nozzles", reason)
+	}
+	raw["compatible_printers_condition"] = `nozzle_diameter[0] == 1.0`
+	if ok, _, _ := target.Compatible(raw); ok {
+		t.Error("Compatible() = true for a nozzle the printer does not take")
+	}
+}
+
+func TestUnevaluatedCondition(t *testing.T) {
+	for _, condition := range []string{
+		`printer_structure == "corexy"`,
+		`printer_model == "Creality K2 Plus" and`,
+		`printer_model =~ /unterminated`,
+		`printer_model == "Creality K2 Plus" @ 1`,
+	} {
+		t.Run(condition, func(t *testing.T) {
+			raw := map[string]interface{}{"compatible_printers_condition": condition}
+			if ok, reason, _ := k2Plus.Compatible(raw); ok || !strings.Contains(reason, "cannot evaluate") {
+				t.Errorf("Compatible() = %t (%s), want the profile skipped", ok, reason)
+			}
+
+			lenient := k2Plus
+			lenient.AllowUnevaluated = true
+			if ok, _, warning := lenient.Compatible(raw); !ok || warning == "" {
+				t.Errorf("with AllowUnevaluated Compatible() = %t, warning %q, want true with a warning", ok, warning)
+			}
+		})
+	}
+}
+
+func TestEvaluateConditionUnknownVariable(t *testing.T) {
+	_, err := evaluateCondition(`printer_structure == "corexy"`, k2Plus.variables("0.4"))
+	if !errors.Is(err, errUnknownVariable) {
+		t.Errorf("evaluateCondition() error = %v, want an unknown variable", err)
+	}
+}
+
+func TestScanRegexp(t *testing.T) {
+	tests := []struct {
+		literal, pattern string
+	}{
+		{`/abc/`, `abc`},
+		{`/.*\/.*/ and x`, `.*/.*`},
+		{`/a\.b\d/`, `a\.b\d`},
+		{`/a\\/`, `a\\`},
+	}
+	for _, tt := range tests {
+		pattern, length, err := scanRegexp(tt.literal)
+		if err != nil || pattern != tt.pattern || tt.literal[length-1] != '/' {
+			t.Errorf("scanRegexp(%q) = %q, %d, %v, want %q", tt.literal, pattern, length, err, tt.pattern)
+		}
+	}
+	if _, _, err := scanRegexp(`/a\/`); err == nil {
+		t.Error("scanRegexp accepted an unterminated literal")
+	}
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	variables := map[string]string{"filament_cost": "25", "base.weightPerMeter": "2.98", "filament_type": "PLA"}
+	tests := []struct {
+		expression, want string
+	}{
+		{`filament_cost * base.weightPerMeter / 1000`, "0.0745"},
+		{`-filament_cost + 5`, "-20"},
+		{`filament_cost - -5`, "30"},
+		{`filament_type == "PLA"`, "1"},
+		{`filament_type`, "PLA"},
+	}
+	for _, tt := range tests {
+		got, err := EvaluateExpression(tt.expression, variables)
+		if err != nil || got != tt.want {
+			t.Errorf("EvaluateExpression(%q) = %q, %v, want %q", tt.expression, got, err, tt.want)
+		}
+	}
+	for _, expression := range []string{`filament_cost / 0`, `filament_type * 2`, `missing + 1`} {
+		if got, err := EvaluateExpression(expression, variables); err == nil {
+			t.Errorf("EvaluateExpression(%q) = %q, want an error", expression, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// curaExtension marks Cura material profiles, which are XML rather than JSON.
+const curaExtension = ".fdm_material"
+
+// curaKeys maps Cura material settings onto the OrcaSlicer keys the rest of the pipeline uses.
+// Cura has no separate first-layer temperatures, so they get the same value.
+var curaKeys = map[string][]string{
+	"print temperature":        {"nozzle_temperature", "nozzle_temperature_initial_layer"},
+	"heated bed temperature":   {"hot_plate_temp", "hot_plate_temp_initial_layer"},
+	"build volume temperature": {"chamber_temperature"},
+	"retraction amount":        {"filament_retraction_length"},
+	"retraction speed":         {"filament_retraction_speed"},
+	"print cooling":            {"fan_max_speed"},
+}
+
+// curaMaterial is the part of the fdm_material format the tool uses. Settings inside
+// <machine> blocks only apply to specific Cura printers and are ignored.
+type curaMaterial struct {
+	XMLName  xml.Name `xml:"fdmmaterial"`
+	Metadata struct {
+		Name struct {
+			Brand    string `xml:"brand"`
+			Material string `xml:"material"`
+			Color    string `xml:"color"`
+			Label    string `xml:"label"`
+		} `xml:"name"`
+		GUID        string `xml:"GUID"`
+		ColorCode   string `xml:"color_code"`
+		Description string `xml:"description"`
+	} `xml:"metadata"`
+	Properties struct {
+		Density  string `xml:"density"`
+		Diameter string `xml:"diameter"`
+	} `xml:"properties"`
+	Settings []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"settings>setting"`
+}
+
+// readCuraProfile reads a Cura material profile. The sync metadata is read from its
+// description, the only free-text field Cura shows for a material.
+func readCuraProfile(filePath string) (*SlicerFilamentProfile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	raw, err := parseCuraMaterial(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Cura material from %s: %w", filePath, err)
+	}
+
+	return &SlicerFilamentProfile{
+		Name:    firstString(raw["name"]),
+		RawData: raw,
+		Path:    filePath,
+	}, nil
+}
+
+// parseCuraMaterial turns a Cura material into raw profile data with OrcaSlicer keys. Values
+// are one-element arrays, like the per-extruder values of an OrcaSlicer preset.
+func parseCuraMaterial(data []byte) (map[string]interface{}, error) {
+	var material curaMaterial
+	if err := xml.Unmarshal(data, &material); err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	set := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			raw[key] = []interface{}{value}
+		}
+	}
+
+	// Cura shows "<brand> <material>" when there is no label, with the color if it is not generic
+	name := material.Metadata.Name
+	label := strings.TrimSpace(name.Label)
+	if label == "" {
+		parts := []string{strings.TrimSpace(name.Brand), strings.TrimSpace(name.Material)}
+		if color := strings.TrimSpace(name.Color); color != "" && color != "Generic" {
+			parts = append(parts, color)
+		}
+		label = strings.TrimSpace(strings.Join(parts, " "))
+	}
+	if label == "" {
+		return nil, fmt.Errorf("material has no name")
+	}
+	raw["name"] = label
+
+	set("filament_vendor", name.Brand)
+	set("filament_type", name.Material)
+	set("filament_id", material.Metadata.GUID) // Stays the same when the material is renamed
+	set("default_filament_colour", material.Metadata.ColorCode)
+	set("filament_density", material.Properties.Density)
+	set("filament_diameter", material.Properties.Diameter)
+	raw["filament_notes"] = []interface{}{material.Metadata.Description}
+
+	for _, setting := range material.Settings {
+		for _, key := range curaKeys[setting.Key] {
+			set(key, setting.Value)
+		}
+	}
+	return raw, nil
+}
+
+// writeCuraNotes sets the description of a Cura material to the metadata, keeping any text it
+// already has. A material without a description gets one at the end of its metadata.
+func writeCuraNotes(data []byte, metadata string) ([]byte, error) {
+	material := &curaMaterial{}
+	if err := xml.Unmarshal(data, material); err != nil {
+		return nil, err
+	}
+
+	// Only what must be escaped in element text, so the JSON stays readable in the file
+	escaper := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	element := "<description>" + escaper.Replace(notesText(material.Metadata.Description, metadata)) + "</description>"
+
+	if start := bytes.Index(data, []byte("<description>")); start >= 0 {
+		end := bytes.Index(data[start:], []byte("</description>"))
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated <description>")
+		}
+		end += start + len("</description>")
+		return spliceBytes(data, start, end, element), nil
+	}
+	if start := bytes.Index(data, []byte("<description/>")); start >= 0 {
+		return spliceBytes(data, start, start+len("<description/>"), element), nil
+	}
+
+	end := bytes.Index(data, []byte("</metadata>"))
+	if end < 0 {
+		return nil, fmt.Errorf("material has no <metadata> element")
+	}
+	// Indent like the closing tag, one level deeper
+	lineStart := bytes.LastIndexByte(data[:end], '\n') + 1
+	indent := string(data[lineStart:end])
+	if strings.TrimSpace(indent) != "" {
+		return spliceBytes(data, end, end, element), nil
+	}
+	return spliceBytes(data, lineStart, lineStart, indent+indent+element+"\n"), nil
+}
+
+// spliceBytes replaces data[start:end] with text.
+func spliceBytes(data []byte, start, end int, text string) []byte {
+	result := append([]byte(nil), data[:start]...)
+	result = append(result, text...)
+	return append(result, data[end:]...)
+}
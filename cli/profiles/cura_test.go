@@ -0,0 +1,38 @@
+package profiles
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadCuraProfile(t *testing.T) {
+	path := filepath.Join("testdata", "acme_pla.fdm_material")
+	profile, err := ReadSlicerProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The <machine> block only applies to one Cura printer, so its temperature is ignored
+	want := map[string]interface{}{
+		"name":                             "Acme PLA Red",
+		"filament_vendor":                  []interface{}{"Acme"},
+		"filament_type":                    []interface{}{"PLA"},
+		"filament_id":                      []interface{}{"0e01be8c-e425-4fb1-b4a3-b79f255f1db9"},
+		"default_filament_colour":          []interface{}{"#ff0000"},
+		"filament_density":                 []interface{}{"1.24"},
+		"filament_diameter":                []interface{}{"1.75"},
+		"filament_notes":                   []interface{}{`{"id":"90021","vendor":"Acme","type":"PLA","name":"Acme PLA Red"}`},
+		"nozzle_temperature":               []interface{}{"205"},
+		"nozzle_temperature_initial_layer": []interface{}{"205"},
+		"hot_plate_temp":                   []interface{}{"60"},
+		"hot_plate_temp_initial_layer":     []interface{}{"60"},
+		"filament_retraction_length":       []interface{}{"0.8"},
+	}
+	if profile.Name != "Acme PLA Red" {
+		t.Errorf("ReadSlicerProfile() name = %q", profile.Name)
+	}
+	if !reflect.DeepEqual(profile.RawData, want) {
+		t.Errorf("ReadSlicerProfile() RawData = %#v, want %#v", profile.RawData, want)
+	}
+}
@@ -0,0 +1,130 @@
+package profiles
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// filterFields lists the profile attributes a filter rule can match on.
+var filterFields = []string{"id", "vendor", "type", "name", "file"}
+
+// FilterRule matches one profile attribute against a glob pattern, e.g. "vendor:Elegoo" or "name:*Silk*".
+type FilterRule struct {
+	Field   string
+	Pattern string
+}
+
+// Filter selects which profiles are synced.
+// A profile is selected when it matches at least one include rule (or there are none)
+// and matches no exclude rule.
+type Filter struct {
+	Include []FilterRule
+	Exclude []FilterRule
+}
+
+// ParseFilterRule parses a "field:pattern" rule.
+func ParseFilterRule(rule string) (FilterRule, error) {
+	field, pattern, ok := strings.Cut(rule, ":")
+	field = strings.ToLower(strings.TrimSpace(field))
+	if !ok || pattern == "" {
+		return FilterRule{}, fmt.Errorf("invalid filter rule %q: expected <field>:<pattern> with field one of %s", rule, strings.Join(filterFields, ", "))
+	}
+
+	known := false
+	for _, f := range filterFields {
+		if f == field {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return FilterRule{}, fmt.Errorf("invalid filter rule %q: unknown field %q, expected one of %s", rule, field, strings.Join(filterFields, ", "))
+	}
+
+	// Validate the glob once so a typo fails up front instead of never matching
+	if _, err := path.Match(pattern, ""); err != nil {
+		return FilterRule{}, fmt.Errorf("invalid filter rule %q: %w", rule, err)
+	}
+
+	return FilterRule{Field: field, Pattern: pattern}, nil
+}
+
+// NewFilter builds a Filter from include and exclude rule strings.
+func NewFilter(include, exclude []string) (*Filter, error) {
+	filter := &Filter{}
+	for _, rule := range include {
+		parsed, err := ParseFilterRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		filter.Include = append(filter.Include, parsed)
+	}
+	for _, rule := range exclude {
+		parsed, err := ParseFilterRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		filter.Exclude = append(filter.Exclude, parsed)
+	}
+	return filter, nil
+}
+
+// Empty reports whether the filter selects every profile.
+func (f *Filter) Empty() bool {
+	return f == nil || (len(f.Include) == 0 && len(f.Exclude) == 0)
+}
+
+// Match reports whether the profile read from filePath with the given notes is selected,
+// and if not, the rule that rejected it.
+func (f *Filter) Match(filePath string, notes *FilamentNotes) (bool, string) {
+	if f.Empty() {
+		return true, ""
+	}
+
+	for _, rule := range f.Exclude {
+		if rule.matches(filePath, notes) {
+			return false, "excluded by " + rule.String()
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return true, ""
+	}
+	for _, rule := range f.Include {
+		if rule.matches(filePath, notes) {
+			return true, ""
+		}
+	}
+	return false, "not matched by any include rule"
+}
+
+// String returns the rule in its "field:pattern" form.
+func (r FilterRule) String() string {
+	return r.Field + ":" + r.Pattern
+}
+
+// matches applies the rule to one profile. Vendor, type and name compare case-insensitively;
+// file patterns are tried against the base name and the full slash-separated path.
+func (r FilterRule) matches(filePath string, notes *FilamentNotes) bool {
+	switch r.Field {
+	case "id":
+		return globMatch(r.Pattern, notes.ID)
+	case "vendor":
+		return globMatch(strings.ToLower(r.Pattern), strings.ToLower(notes.Vendor))
+	case "type":
+		return globMatch(strings.ToLower(r.Pattern), strings.ToLower(notes.Type))
+	case "name":
+		return globMatch(strings.ToLower(r.Pattern), strings.ToLower(notes.Name))
+	case "file":
+		return globMatch(r.Pattern, filepath.Base(filePath)) || globMatch(filepath.ToSlash(r.Pattern), filepath.ToSlash(filePath))
+	}
+	return false
+}
+
+// globMatch is path.Match with malformed patterns treated as non-matching.
+func globMatch(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
@@ -0,0 +1,95 @@
+package profiles
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Markers around the settings OrcaSlicer and Creality Print append to every G-code file.
+const (
+	configBlockStart = "; CONFIG_BLOCK_START"
+	configBlockEnd   = "; CONFIG_BLOCK_END"
+)
+
+// ReadGCodeProfiles extracts the filament presets a G-code file was sliced with, from the
+// config block at its end. The block holds the settings as they were at export time, including
+// unsaved changes. Slots the print does not use are left out when the file says so.
+func ReadGCodeProfiles(path string) ([]*SlicerFilamentProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open G-code %s: %w", path, err)
+	}
+	defer file.Close()
+
+	settings := make(map[string]interface{})
+	var used []bool
+	inBlock, foundBlock := false, false
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Custom G-code settings can be long
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, ";") {
+			continue // Moves, which are most of the file
+		}
+		switch line {
+		case configBlockStart:
+			inBlock, foundBlock = true, true
+			continue
+		case configBlockEnd:
+			inBlock = false
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimSpace(line[1:]), " = ")
+		if !found {
+			continue
+		}
+		if !inBlock {
+			// The summary before the block tells which slots the print actually uses
+			if key == "filament used [mm]" {
+				used = usedSlots(value)
+			}
+			continue
+		}
+
+		// Values use the same encoding as PrusaSlicer presets. The block also holds the print and
+		// printer settings, so one value the tool cannot read must not lose every filament.
+		parsed, err := parseIniValue(strings.TrimSpace(value))
+		if err != nil {
+			log.Printf("Warning: ignoring %s in the config block of %s: %v", key, path, err)
+			continue
+		}
+		if _, isList := parsed.([]interface{}); !isList {
+			// A project with one filament writes its per-filament settings as plain values
+			parsed = []interface{}{parsed}
+		}
+		settings[key] = parsed
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read G-code %s: %w", path, err)
+	}
+	if !foundBlock {
+		return nil, fmt.Errorf("%s has no config block; only OrcaSlicer and Creality Print write one", path)
+	}
+
+	return splitFilamentSlots(settings, path, used)
+}
+
+// usedSlots reads the per-slot lengths of a "filament used [mm]" line; a slot with no length
+// is unused.
+func usedSlots(value string) []bool {
+	var used []bool
+	for _, length := range strings.Split(value, ",") {
+		mm, err := strconv.ParseFloat(strings.TrimSpace(length), 64)
+		if err != nil {
+			return nil // Unknown format: keep every slot
+		}
+		used = append(used, mm > 0)
+	}
+	return used
+}
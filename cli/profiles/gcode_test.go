@@ -0,0 +1,57 @@
+package profiles
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadGCodeProfiles(t *testing.T) {
+	path := filepath.Join("testdata", "three_slots.gcode")
+	presets, err := ReadGCodeProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Slot 2 has no length in "filament used [mm]"; the unterminated machine_end_gcode is skipped
+	want := []*SlicerFilamentProfile{
+		{
+			Name: "Acme PLA",
+			Path: path + "#1",
+			RawData: map[string]interface{}{
+				"name":                    "Acme PLA",
+				"filament_id":             "GFL99",
+				"default_filament_colour": []interface{}{"#FF0000"},
+				"filament_start_gcode":    []interface{}{"; filament start gcode\nM106 P3 S0"},
+				"filament_type":           []interface{}{"PLA"},
+				"nozzle_temperature":      []interface{}{"210"},
+				"compatible_printers":     []interface{}{"Creality K2 Plus 0.4 nozzle"},
+			},
+		},
+		{
+			Name: "Acme PLA Silk",
+			Path: path + "#3",
+			RawData: map[string]interface{}{
+				"name":                    "Acme PLA Silk",
+				"filament_id":             "P1d2b3a4",
+				"default_filament_colour": []interface{}{"#0000FF"},
+				"filament_start_gcode":    []interface{}{"; silk; slow\nM220 S90"},
+				"filament_type":           []interface{}{"PLA"},
+				"nozzle_temperature":      []interface{}{"215"},
+				"compatible_printers":     []interface{}{"Creality K2 Plus 0.4 nozzle"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(presets, want) {
+		for _, preset := range presets {
+			t.Logf("%s %s: %#v", preset.Name, preset.Path, preset.RawData)
+		}
+		t.Errorf("ReadGCodeProfiles() does not match the fixture")
+	}
+}
+
+func TestReadGCodeProfilesWithoutConfigBlock(t *testing.T) {
+	if _, err := ReadGCodeProfiles(filepath.Join("testdata", "prusa_pla.ini")); err == nil {
+		t.Error("ReadGCodeProfiles() of a file without a config block succeeded")
+	}
+}
@@ -0,0 +1,205 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IdentityMode selects where a profile's ID, vendor, type and name come from.
+type IdentityMode string
+
+const (
+	IdentityNotes  IdentityMode = "notes"  // JSON in filament_notes (the original behaviour)
+	IdentityPreset IdentityMode = "preset" // The slicer's own preset fields, filament_notes is ignored
+	IdentityAuto   IdentityMode = "auto"   // filament_notes when it carries an ID, preset fields otherwise
+)
+
+// Derived IDs are 5 digits in this range, above the IDs used by stock Creality filaments.
+const (
+	derivedIDMin   = 50000
+	derivedIDRange = 50000
+)
+
+// IDMapFile is the default name of the ID mapping file in the data directory.
+const IDMapFile = "id_map.json"
+
+// ParseIdentityMode validates an --identity value.
+func ParseIdentityMode(value string) (IdentityMode, error) {
+	switch mode := IdentityMode(strings.ToLower(value)); mode {
+	case IdentityNotes, IdentityPreset, IdentityAuto:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid identity mode %q, expected notes, preset or auto", value)
+}
+
+// Identity decides which custom profiles are synced and under which ID.
+type Identity struct {
+	Mode IdentityMode
+	Pins map[string]string // Preset name, filament_settings_id, filament_id or file name to a fixed ID
+}
+
+// idMap is the on-disk format of the ID mapping file.
+type idMap struct {
+	IDs map[string]string `json:"ids"`
+}
+
+// NewIdentity returns an Identity for mode with the pins of the mapping file at mapPath.
+// A missing mapping file is not an error unless required is set.
+func NewIdentity(mode IdentityMode, mapPath string, required bool) (*Identity, error) {
+	identity := &Identity{Mode: mode, Pins: make(map[string]string)}
+	if mapPath == "" {
+		return identity, nil
+	}
+
+	data, err := os.ReadFile(mapPath)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return identity, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ID map %s: %w", mapPath, err)
+	}
+
+	var mapping idMap
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse ID map %s: %w", mapPath, err)
+	}
+	for key, id := range mapping.IDs {
+		if strings.TrimSpace(id) == "" {
+			return nil, fmt.Errorf("ID map %s pins %q to an empty ID", mapPath, key)
+		}
+		identity.Pins[key] = id
+	}
+	return identity, nil
+}
+
+// Notes returns the sync metadata of a raw slicer profile read from filePath.
+func (i *Identity) Notes(raw map[string]interface{}, filePath string) (*FilamentNotes, error) {
+	mode := IdentityNotes
+	if i != nil {
+		mode = i.Mode
+	}
+
+	switch mode {
+	case IdentityPreset:
+		return i.presetNotes(raw, filePath)
+	case IdentityAuto:
+		if notes, err := NotesFromProfile(raw); err == nil {
+			return notes, nil
+		}
+		return i.presetNotes(raw, filePath)
+	default:
+		return NotesFromProfile(raw)
+	}
+}
+
+// noMetadataError explains why a profile has no sync metadata; it matches ErrNoNotesMetadata.
+type noMetadataError string
+
+func (e noMetadataError) Error() string        { return string(e) }
+func (e noMetadataError) Is(target error) bool { return target == ErrNoNotesMetadata }
+
+// NotesFromProfile parses the sync metadata stored in a raw profile's filament_notes. The error
+// matches ErrNoNotesMetadata when there is none, as opposed to metadata that is invalid.
+func NotesFromProfile(raw map[string]interface{}) (*FilamentNotes, error) {
+	notesVal, ok := raw["filament_notes"]
+	if !ok {
+		return nil, noMetadataError("missing required 'filament_notes' field")
+	}
+	notesArr, isArray := notesVal.([]interface{})
+	if !isArray || len(notesArr) == 0 {
+		return nil, noMetadataError("filament_notes missing/invalid format")
+	}
+	noteStr, isString := notesArr[0].(string)
+	if !isString {
+		return nil, noMetadataError("filament_notes empty/invalid")
+	}
+
+	// The metadata may be surrounded by the user's own remarks
+	notes, err := ExtractNotes(noteStr)
+	if err == ErrNoNotesMetadata {
+		return nil, noMetadataError("filament_notes empty/invalid")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filament_notes invalid: %v. Inner content: %s", err, strings.TrimSpace(noteStr))
+	}
+	return notes, nil
+}
+
+// presetNotes builds the sync metadata from the slicer's own fields: the ID is pinned in the
+// mapping file or derived from filament_id (or the preset name), vendor and type come from
+// filament_vendor and filament_type, and the name from the preset name.
+func (i *Identity) presetNotes(raw map[string]interface{}, filePath string) (*FilamentNotes, error) {
+	name := firstString(raw["name"])
+	settingsID := firstString(raw["filament_settings_id"])
+	filamentID := firstString(raw["filament_id"])
+	fileName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	if name == "" && settingsID == "" && filamentID == "" {
+		return nil, fmt.Errorf("no filament_notes ID and no preset name or filament_id to derive one from")
+	}
+
+	// Pins win, looked up from the most to the least specific key
+	id := ""
+	if i != nil {
+		for _, key := range []string{name, settingsID, filamentID, fileName} {
+			if pinned, ok := i.Pins[key]; ok && key != "" {
+				id = pinned
+				break
+			}
+		}
+	}
+	if id == "" {
+		// filament_id survives renames in the slicer, the preset name does not
+		key := filamentID
+		if key == "" {
+			key = settingsID
+		}
+		if key == "" {
+			key = name
+		}
+		id = DeriveID(key)
+	}
+
+	displayName := name
+	if displayName == "" {
+		displayName = settingsID
+	}
+	// Printer-specific presets are named "<filament> @<printer> <nozzle>"
+	if at := strings.Index(displayName, " @"); at > 0 {
+		displayName = displayName[:at]
+	}
+
+	return &FilamentNotes{
+		ID:     id,
+		Vendor: firstString(raw["filament_vendor"]),
+		Type:   firstString(raw["filament_type"]),
+		Name:   strings.TrimSpace(displayName),
+	}, nil
+}
+
+// DeriveID turns a preset key into a stable 5-digit ID.
+func DeriveID(key string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return fmt.Sprintf("%05d", derivedIDMin+int(hash.Sum32()%derivedIDRange))
+}
+
+// firstString returns a string value, or the first element of a string array, trimmed.
+func firstString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,303 @@
+package profiles
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxInheritanceDepth guards against runaway chains; real presets are 3-4 levels deep.
+const maxInheritanceDepth = 16
+
+//go:embed data/filament_defaults.json
+var defaultsJSON []byte // Last-resort values for keys no preset in the chain sets
+
+// notInherited lists keys that describe the preset itself rather than the filament,
+// so a child never takes them from its parent.
+var notInherited = map[string]bool{
+	"name":                      true,
+	"inherits":                  true,
+	"from":                      true,
+	"instantiation":             true,
+	"setting_id":                true,
+	"filament_id":               true,
+	"filament_settings_id":      true,
+	"filament_notes":            true,
+	"is_custom_defined":         true,
+	"version":                   true,
+	"type":                      true,
+	"base_id":                   true,
+	"updated_time":              true,
+	"user_id":                   true,
+	"filament_extruder_variant": true,
+}
+
+// Resolver flattens slicer presets by following their "inherits" chain: user presets first,
+// then the slicer's system vendor profiles, then built-in defaults.
+type Resolver struct {
+	userPresets   map[string]string            // Preset name to file path
+	systemPresets map[string]map[string]string // Vendor directory to preset name to file path
+	vendors       []string                     // Vendor directories in search order
+	systemDir     string
+	defaults      map[string]interface{}
+	cache         map[string]map[string]interface{} // Flattened presets by vendor and name
+}
+
+// NewResolver indexes the user preset directories (top level only) and, if systemDir is not
+// empty, every filament preset below systemDir by the vendor directory it belongs to.
+func NewResolver(userDirs []string, systemDir string) (*Resolver, error) {
+	r := &Resolver{
+		userPresets:   make(map[string]string),
+		systemPresets: make(map[string]map[string]string),
+		systemDir:     systemDir,
+		cache:         make(map[string]map[string]interface{}),
+	}
+
+	if err := json.Unmarshal(defaultsJSON, &r.defaults); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded filament defaults: %w", err)
+	}
+
+	for _, dir := range userDirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue // Optional locations such as the parent of filament/base
+		}
+		for _, file := range files {
+			if file.IsDir() || !isProfileFile(file.Name()) {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+			if name := presetName(path); name != "" {
+				if _, exists := r.userPresets[name]; !exists {
+					r.userPresets[name] = path
+				}
+			}
+		}
+	}
+
+	if systemDir != "" {
+		err := filepath.WalkDir(systemDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+				return nil
+			}
+			// Vendor bundles keep filament presets in a "filament" directory next to machine/process ones
+			if !strings.Contains(filepath.ToSlash(path), "/filament/") {
+				return nil
+			}
+			name := presetName(path)
+			if name == "" {
+				return nil
+			}
+			vendor := r.vendorOf(path)
+			presets, ok := r.systemPresets[vendor]
+			if !ok {
+				presets = make(map[string]string)
+				r.systemPresets[vendor] = presets
+				r.vendors = append(r.vendors, vendor)
+			}
+			if _, exists := presets[name]; !exists {
+				presets[name] = path
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to index system profiles in %s: %w", systemDir, err)
+		}
+		sort.Strings(r.vendors)
+	}
+
+	return r, nil
+}
+
+// DefaultSystemDir guesses the slicer's system profile directory from a user profile path such
+// as <config>/OrcaSlicer/user/<id>/filament/base, returning "" if none is found.
+func DefaultSystemDir(profilePath string) string {
+	dir, err := filepath.Abs(profilePath)
+	if err != nil {
+		return ""
+	}
+	for {
+		if filepath.Base(dir) == "user" {
+			systemDir := filepath.Join(filepath.Dir(dir), "system")
+			if stat, err := os.Stat(systemDir); err == nil && stat.IsDir() {
+				return systemDir
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Resolve merges the settings inherited by profile into its RawData. Keys the profile sets
+// itself always win. A missing parent stops the chain with an error, but the keys resolved
+// so far (and the defaults) are still applied. The keys filled from the defaults are recorded
+// in Defaulted.
+func (r *Resolver) Resolve(profile *SlicerFilamentProfile) error {
+	chainErr := r.ResolveInherited(profile)
+	profile.Defaulted = make(map[string]bool)
+	for key, value := range r.defaults {
+		if _, ok := profile.RawData[key]; !ok {
+			profile.RawData[key] = value
+			profile.Defaulted[key] = true
+		}
+	}
+	return chainErr
+}
+
+// ResolveInherited is Resolve without the built-in defaults, so settings no preset in the
+// chain sets stay missing.
+func (r *Resolver) ResolveInherited(profile *SlicerFilamentProfile) error {
+	parentName := inheritsName(profile.RawData)
+
+	var inherited map[string]interface{}
+	var chainErr error
+	if parentName != "" {
+		inherited, chainErr = r.flatten(parentName, r.vendorOf(profile.Path), map[string]bool{profile.Name: true}, 1)
+	}
+
+	for key, value := range inherited {
+		if _, ok := profile.RawData[key]; !ok && !notInherited[key] {
+			profile.RawData[key] = value
+		}
+	}
+	return chainErr
+}
+
+// flatten returns the fully inherited settings of the named preset. vendor is the vendor
+// directory of the child asking for it, or "" for a user preset.
+func (r *Resolver) flatten(name, vendor string, visiting map[string]bool, depth int) (map[string]interface{}, error) {
+	cacheKey := vendor + "/" + name
+	if cached, ok := r.cache[cacheKey]; ok {
+		return cached, nil
+	}
+	if visiting[name] {
+		return nil, fmt.Errorf("inheritance cycle through %q", name)
+	}
+	if depth > maxInheritanceDepth {
+		return nil, fmt.Errorf("inheritance chain deeper than %d presets at %q", maxInheritanceDepth, name)
+	}
+
+	path, ok := r.userPresets[name]
+	parentVendor := vendor
+	if !ok {
+		path, parentVendor, ok = r.findSystemPreset(name, vendor)
+	}
+	if !ok {
+		return nil, fmt.Errorf("parent preset %q not found in user presets or system profiles", name)
+	}
+
+	raw, err := ReadRawProfile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parent preset %s: %w", path, err)
+	}
+
+	flattened := make(map[string]interface{}, len(raw))
+	var chainErr error
+	if parentName := inheritsName(raw); parentName != "" {
+		visiting[name] = true
+		var parent map[string]interface{}
+		parent, chainErr = r.flatten(parentName, parentVendor, visiting, depth+1)
+		delete(visiting, name)
+		for key, value := range parent {
+			flattened[key] = value
+		}
+	}
+	for key, value := range raw {
+		flattened[key] = value
+	}
+
+	// Only complete chains are cached so a broken one is reported for every child
+	if chainErr == nil {
+		r.cache[cacheKey] = flattened
+	}
+	return flattened, chainErr
+}
+
+// findSystemPreset looks the named preset up in the given vendor directory first. Vendors
+// reuse base names such as fdm_filament_pla with different settings, so taking another
+// vendor's preset is logged.
+func (r *Resolver) findSystemPreset(name, vendor string) (path, foundVendor string, ok bool) {
+	if path, ok := r.systemPresets[vendor][name]; ok {
+		return path, vendor, true
+	}
+	var matches []string
+	for _, candidate := range r.vendors {
+		if _, ok := r.systemPresets[candidate][name]; ok {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", "", false
+	}
+	foundVendor = matches[0]
+	if vendor != "" {
+		log.Printf("Warning: parent preset %q not found in system vendor %s, using the one from %s",
+			name, vendor, foundVendor)
+	} else if len(matches) > 1 {
+		log.Printf("Warning: parent preset %q exists in system vendors %s, using the one from %s",
+			name, strings.Join(matches, ", "), foundVendor)
+	}
+	return r.systemPresets[foundVendor][name], foundVendor, true
+}
+
+// vendorOf returns the vendor directory below the system directory that contains path, or ""
+// if path is not a system preset.
+func (r *Resolver) vendorOf(path string) string {
+	if r.systemDir == "" || path == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(r.systemDir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	vendor, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return vendor
+}
+
+// inheritsName returns the parent preset name of a raw preset, or "".
+func inheritsName(raw map[string]interface{}) string {
+	switch value := raw["inherits"].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case []interface{}:
+		if len(value) > 0 {
+			if s, ok := value[0].(string); ok {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+// presetName reads the "name" field of a preset file, returning "" if it has none. Presets of
+// other slicers are named the way those slicers name them.
+func presetName(path string) string {
+	if profile, ok, err := readForeignProfile(path); ok {
+		if err != nil {
+			return ""
+		}
+		return profile.Name
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Skipping %s while indexing presets: %v", path, err)
+		return ""
+	}
+	var header struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return ""
+	}
+	return header.Name
+}
@@ -0,0 +1,73 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePreset writes a preset file with the given JSON body below root.
+func writePreset(t *testing.T, root, rel, body string) string {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveInheritedPrefersOwnVendor(t *testing.T) {
+	root := t.TempDir()
+	systemDir := filepath.Join(root, "system")
+	userDir := filepath.Join(root, "user")
+
+	// Both vendors ship a base preset with the same name but different settings; Anker sorts first
+	writePreset(t, systemDir, "Anker/filament/fdm_filament_pla.json",
+		`{"name":"fdm_filament_pla","nozzle_temperature":["190"],"filament_density":["1.20"]}`)
+	writePreset(t, systemDir, "Creality/filament/fdm_filament_pla.json",
+		`{"name":"fdm_filament_pla","nozzle_temperature":["220"]}`)
+	writePreset(t, systemDir, "Creality/filament/Creality Generic PLA.json",
+		`{"name":"Creality Generic PLA","inherits":"fdm_filament_pla","filament_vendor":["Creality"]}`)
+	writePreset(t, systemDir, "Creality/filament/Creality Hyper PLA.json",
+		`{"name":"Creality Hyper PLA","inherits":"fdm_filament_shared"}`)
+	writePreset(t, systemDir, "Anker/filament/fdm_filament_shared.json",
+		`{"name":"fdm_filament_shared","nozzle_temperature":["200"]}`)
+	userPath := writePreset(t, userDir, "My PLA.json",
+		`{"name":"My PLA","inherits":"Creality Generic PLA"}`)
+
+	resolver, err := NewResolver([]string{userDir}, systemDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path, key string
+		want            string
+	}{
+		{"system preset takes its own vendor's parent", filepath.Join(systemDir, "Creality/filament/Creality Generic PLA.json"), "nozzle_temperature", "220"},
+		{"user preset follows the vendor of its system parent", userPath, "nozzle_temperature", "220"},
+		{"missing keys are not taken from another vendor's parent", userPath, "filament_density", ""},
+		{"parent only another vendor has is used as a fallback", filepath.Join(systemDir, "Creality/filament/Creality Hyper PLA.json"), "nozzle_temperature", "200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ReadSlicerProfile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := resolver.ResolveInherited(profile); err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if values, ok := profile.RawData[tt.key].([]interface{}); ok && len(values) > 0 {
+				got, _ = values[0].(string)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package profiles
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// iniExtension marks PrusaSlicer/SuperSlicer presets, which are INI files rather than JSON.
+const iniExtension = ".ini"
+
+// prusaKeys maps PrusaSlicer/SuperSlicer filament keys onto the OrcaSlicer keys the rest of the
+// pipeline uses. Keys both slicers share, and keys without an equivalent, are kept as they are.
+var prusaKeys = map[string]string{
+	"temperature":                    "nozzle_temperature",
+	"first_layer_temperature":        "nozzle_temperature_initial_layer",
+	"bed_temperature":                "hot_plate_temp",
+	"first_layer_bed_temperature":    "hot_plate_temp_initial_layer",
+	"filament_colour":                "default_filament_colour",
+	"extrusion_multiplier":           "filament_flow_ratio",
+	"cooling":                        "slow_down_for_layer_cooling",
+	"max_fan_speed":                  "fan_max_speed",
+	"min_fan_speed":                  "fan_min_speed",
+	"bridge_fan_speed":               "overhang_fan_speed",
+	"disable_fan_first_layers":       "close_fan_the_first_x_layers",
+	"fan_below_layer_time":           "fan_cooling_layer_time",
+	"slowdown_below_layer_time":      "slow_down_layer_time",
+	"min_print_speed":                "slow_down_min_speed",
+	"start_filament_gcode":           "filament_start_gcode",
+	"end_filament_gcode":             "filament_end_gcode",
+	"filament_retract_length":        "filament_retraction_length",
+	"filament_retract_speed":         "filament_retraction_speed",
+	"filament_deretract_speed":       "filament_deretraction_speed",
+	"filament_retract_lift":          "filament_z_hop",
+	"filament_retract_before_travel": "filament_retraction_minimum_travel",
+	"filament_retract_layer_change":  "filament_retract_when_changing_layer",
+}
+
+// readIniProfile reads a PrusaSlicer/SuperSlicer filament preset. The INI file does not store
+// the preset name, so it is taken from the file name, as the slicer does.
+func readIniProfile(filePath string) (*SlicerFilamentProfile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	raw, err := parseIniPreset(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse INI from %s: %w", filePath, err)
+	}
+	if _, ok := raw["name"]; !ok {
+		raw["name"] = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	}
+
+	return &SlicerFilamentProfile{
+		Name:    firstString(raw["name"]),
+		Version: firstString(raw["version"]),
+		RawData: raw,
+		Path:    filePath,
+	}, nil
+}
+
+// parseIniPreset turns the "key = value" lines of a single preset into raw profile data with
+// OrcaSlicer keys and JSON-like values: lists become arrays, everything else a string.
+func parseIniPreset(data []byte) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // G-code values can be long
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			// Config bundles hold many presets in [filament:<name>] sections
+			return nil, fmt.Errorf("line %d: config bundles are not supported, export the filament preset on its own", lineNumber)
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected \"key = value\"", lineNumber)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		parsed, err := parseIniValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNumber, key, err)
+		}
+
+		switch key {
+		case "filament_shrinkage_compensation_xy":
+			// PrusaSlicer stores how much the part shrinks, OrcaSlicer the size that remains
+			if shrink, ok := prusaShrink(parsed); ok {
+				raw["filament_shrink"] = shrink
+			}
+			continue
+		case "filament_notes":
+			// Always a one-element array, as in OrcaSlicer presets
+			if text, ok := parsed.(string); ok {
+				parsed = []interface{}{text}
+			}
+		}
+		if mapped, ok := prusaKeys[key]; ok {
+			key = mapped
+		}
+		raw[key] = parsed
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// parseIniValue decodes one INI value. Strings are quoted and C-escaped when they contain
+// special characters, string vectors are strings joined by ";", and numeric vectors are
+// joined by ",".
+func parseIniValue(value string) (interface{}, error) {
+	if strings.HasPrefix(value, `"`) || strings.Contains(value, ";") {
+		items, err := parseStringList(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = item
+		}
+		return list, nil
+	}
+
+	if strings.Contains(value, ",") {
+		parts := strings.Split(value, ",")
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if _, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64); err != nil && part != "nil" {
+				// Not a numeric vector, e.g. a condition such as "nozzle_diameter[0]==0.4, ..."
+				return value, nil
+			}
+			list[i] = part
+		}
+		return list, nil
+	}
+	return value, nil
+}
+
+// parseStringList decodes `"a";b` into its unescaped strings. Only strings with special
+// characters are quoted, so a list may mix quoted and bare items.
+func parseStringList(value string) ([]string, error) {
+	var items []string
+	rest := value
+	for {
+		if !strings.HasPrefix(rest, `"`) {
+			item, remainder, more := strings.Cut(rest, ";")
+			items = append(items, strings.TrimSpace(item))
+			if !more {
+				return items, nil
+			}
+			rest = strings.TrimSpace(remainder)
+			continue
+		}
+
+		var item strings.Builder
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] != '\\' || i+1 == len(rest) {
+				item.WriteByte(rest[i])
+				continue
+			}
+			i++
+			switch rest[i] {
+			case 'n':
+				item.WriteByte('\n')
+			case 'r':
+				item.WriteByte('\r')
+			case 't':
+				item.WriteByte('\t')
+			default:
+				item.WriteByte(rest[i]) // \\ and \"
+			}
+		}
+		if i == len(rest) {
+			return nil, fmt.Errorf("unterminated quoted string")
+		}
+		items = append(items, item.String())
+
+		rest = strings.TrimSpace(rest[i+1:])
+		if rest == "" {
+			return items, nil
+		}
+		if !strings.HasPrefix(rest, ";") {
+			return nil, fmt.Errorf("unexpected %q after a quoted string", rest)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+}
+
+// prusaShrink converts filament_shrinkage_compensation_xy (e.g. "0.5%") into an OrcaSlicer
+// filament_shrink value (e.g. "99.5%").
+func prusaShrink(value interface{}) (interface{}, bool) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(firstString(value), "%"), 64)
+	if err != nil {
+		return nil, false
+	}
+	return []interface{}{strconv.FormatFloat(100-percent, 'f', -1, 64) + "%"}, true
+}
+
+// writeIniNotes sets the filament_notes line of an INI preset to the metadata, appending the
+// line when the preset has none. Every other line is left alone.
+func writeIniNotes(data []byte, metadata string) []byte {
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.TrimSpace(key) != "filament_notes" {
+			continue
+		}
+
+		existing := ""
+		if parsed, err := parseIniValue(strings.TrimSpace(value)); err == nil {
+			existing, _ = parsed.(string)
+		}
+		ending := line[len(strings.TrimRight(line, "\r\n")):]
+		lines[i] = "filament_notes = " + quoteIniString(notesText(existing, metadata)) + ending
+		return []byte(strings.Join(lines, ""))
+	}
+
+	result := string(data)
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return []byte(result + "filament_notes = " + quoteIniString(metadata) + "\n")
+}
+
+// quoteIniString quotes and C-escapes a string the way PrusaSlicer writes string options.
+func quoteIniString(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + replacer.Replace(text) + `"`
+}
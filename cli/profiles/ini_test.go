@@ -0,0 +1,62 @@
+package profiles
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadIniProfile(t *testing.T) {
+	path := filepath.Join("testdata", "prusa_pla.ini")
+	profile, err := ReadSlicerProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"name":                             "prusa_pla",
+		"hot_plate_temp":                   "60",
+		"filament_flow_ratio":              "0.98",
+		"filament_density":                 "1.24",
+		"filament_diameter":                "1.75",
+		"filament_notes":                   []interface{}{"Dry before use.\n{\"id\":\"90020\",\"vendor\":\"Acme\",\"type\":\"PLA\",\"name\":\"Acme PLA\"}"},
+		"filament_shrink":                  []interface{}{"99.6%"},
+		"filament_type":                    "PLA",
+		"nozzle_temperature_initial_layer": "215",
+		"filament_start_gcode":             "; Filament gcode\nM900 K0.04",
+		"nozzle_temperature":               "210",
+	}
+	if profile.Name != "prusa_pla" || profile.Path != path {
+		t.Errorf("ReadSlicerProfile() name, path = %q, %q", profile.Name, profile.Path)
+	}
+	if !reflect.DeepEqual(profile.RawData, want) {
+		t.Errorf("ReadSlicerProfile() RawData = %#v, want %#v", profile.RawData, want)
+	}
+}
+
+func TestParseIniValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{"210", "210"},
+		{"210,240", []interface{}{"210", "240"}},
+		{"PLA;PETG", []interface{}{"PLA", "PETG"}},
+		{`"; start; here\n"`, "; start; here\n"},
+		{`"a;b";c`, []interface{}{"a;b", "c"}},
+		{"nozzle_diameter[0]==0.4, 1", "nozzle_diameter[0]==0.4, 1"},
+	}
+	for _, tt := range tests {
+		got, err := parseIniValue(tt.value)
+		if err != nil {
+			t.Errorf("parseIniValue(%q) error: %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseIniValue(%q) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+	if _, err := parseIniValue(`"unterminated`); err == nil {
+		t.Error("parseIniValue() of an unterminated string succeeded")
+	}
+}
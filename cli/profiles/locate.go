@@ -0,0 +1,229 @@
+package profiles
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoProfilePath is the --profile-path value that triggers discovery.
+const AutoProfilePath = "auto"
+
+// DiscoveryRoots are the per-user directories searched for slicer installations.
+// Tests can point them at a fake tree instead of the real home directory.
+type DiscoveryRoots struct {
+	GOOS    string // "linux", "darwin" or "windows"
+	Home    string // User home directory
+	AppData string // %APPDATA% on Windows
+}
+
+// Candidate is a filament profile directory found for one slicer installation and user.
+type Candidate struct {
+	Slicer   string // "OrcaSlicer" or "Creality Print"
+	Version  string // Creality Print version directory, empty for OrcaSlicer
+	UserID   string // "default" or the logged-in user ID
+	Flatpak  bool   // Found in a Flatpak sandbox on Linux
+	Path     string // filament/base directory, or filament/ when base does not exist
+	Modified time.Time
+}
+
+// slicerRoot is the configuration directory of one slicer installation.
+type slicerRoot struct {
+	slicer  string
+	version string
+	flatpak bool
+	dir     string
+}
+
+// DefaultDiscoveryRoots returns the roots of the current user on the current OS.
+func DefaultDiscoveryRoots() DiscoveryRoots {
+	home, _ := os.UserHomeDir()
+	return DiscoveryRoots{GOOS: runtime.GOOS, Home: home, AppData: os.Getenv("APPDATA")}
+}
+
+// DiscoverProfileDirs lists the filament profile directories of every installed OrcaSlicer and
+// Creality Print version and every user ID, most recently modified first.
+func DiscoverProfileDirs(roots DiscoveryRoots) []Candidate {
+	var candidates []Candidate
+	for _, root := range slicerRoots(roots) {
+		userDirs, err := os.ReadDir(filepath.Join(root.dir, "user"))
+		if err != nil {
+			continue
+		}
+		for _, userDir := range userDirs {
+			if !userDir.IsDir() {
+				continue
+			}
+			filamentDir := filepath.Join(root.dir, "user", userDir.Name(), "filament")
+			path := filepath.Join(filamentDir, "base")
+			stat, err := os.Stat(path)
+			if err != nil || !stat.IsDir() {
+				path = filamentDir
+				if stat, err = os.Stat(path); err != nil || !stat.IsDir() {
+					continue
+				}
+			}
+			candidates = append(candidates, Candidate{
+				Slicer:   root.slicer,
+				Version:  root.version,
+				UserID:   userDir.Name(),
+				Flatpak:  root.flatpak,
+				Path:     path,
+				Modified: newestModification(path),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Modified.After(candidates[j].Modified)
+	})
+	return candidates
+}
+
+// slicerRoots returns the slicer configuration directories that exist under roots.
+func slicerRoots(roots DiscoveryRoots) []slicerRoot {
+	var configDirs []string
+	var flatpakConfigDirs []string
+
+	switch roots.GOOS {
+	case "windows":
+		if roots.AppData != "" {
+			configDirs = append(configDirs, roots.AppData)
+		}
+	case "darwin":
+		configDirs = append(configDirs, filepath.Join(roots.Home, "Library", "Application Support"))
+	default:
+		configDirs = append(configDirs, filepath.Join(roots.Home, ".config"))
+		// Flatpak apps keep their configuration in ~/.var/app/<app id>/config
+		apps, _ := filepath.Glob(filepath.Join(roots.Home, ".var", "app", "*", "config"))
+		flatpakConfigDirs = append(flatpakConfigDirs, apps...)
+	}
+
+	var found []slicerRoot
+	add := func(configDir string, flatpak bool) {
+		orca := filepath.Join(configDir, "OrcaSlicer")
+		if isDir(orca) {
+			found = append(found, slicerRoot{slicer: "OrcaSlicer", flatpak: flatpak, dir: orca})
+		}
+
+		versions, _ := os.ReadDir(filepath.Join(configDir, "Creality", "Creality Print"))
+		var names []string
+		for _, version := range versions {
+			if version.IsDir() {
+				names = append(names, version.Name())
+			}
+		}
+		// Newest version first so ties on modification time favour it
+		sort.SliceStable(names, func(i, j int) bool { return compareVersions(names[i], names[j]) > 0 })
+		for _, name := range names {
+			found = append(found, slicerRoot{
+				slicer:  "Creality Print",
+				version: name,
+				flatpak: flatpak,
+				dir:     filepath.Join(configDir, "Creality", "Creality Print", name),
+			})
+		}
+	}
+
+	for _, dir := range configDirs {
+		add(dir, false)
+	}
+	for _, dir := range flatpakConfigDirs {
+		add(dir, true)
+	}
+	return found
+}
+
+// newestModification returns the latest modification time of dir and the files directly in
+// it, which tells the profile directory in active use apart from stale ones.
+func newestModification(dir string) time.Time {
+	var newest time.Time
+	if stat, err := os.Stat(dir); err == nil {
+		newest = stat.ModTime()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return newest
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
+
+// compareVersions compares dotted version strings numerically, e.g. "6.10" > "6.2".
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			if aNum > bNum {
+				return 1
+			}
+			return -1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+// ResolveAutoSources replaces every "auto" entry in sources with the most recently used
+// discovered profile directory. The other candidates are logged so users can pin one.
+func ResolveAutoSources(sources []string, roots DiscoveryRoots) ([]string, error) {
+	var resolved []string
+	var candidates []Candidate
+	discovered := false
+
+	for _, source := range sources {
+		if !strings.EqualFold(source, AutoProfilePath) {
+			resolved = append(resolved, source)
+			continue
+		}
+		if !discovered {
+			candidates = DiscoverProfileDirs(roots)
+			discovered = true
+			if len(candidates) == 0 {
+				return nil, fmt.Errorf("--profile-path auto found no OrcaSlicer or Creality Print profile directory; pass the path explicitly")
+			}
+			log.Printf("Auto-detected %s profile directory: %s", candidates[0].Label(), candidates[0].Path)
+			for _, other := range candidates[1:] {
+				log.Printf("Other profile directory found (%s): %s", other.Label(), other.Path)
+			}
+		}
+		resolved = append(resolved, candidates[0].Path)
+	}
+	return resolved, nil
+}
+
+// Label describes the slicer installation and user of a candidate, e.g.
+// "Creality Print 6.0 (Flatpak), user default".
+func (c Candidate) Label() string {
+	label := c.Slicer
+	if c.Version != "" {
+		label += " " + c.Version
+	}
+	if c.Flatpak {
+		label += " (Flatpak)"
+	}
+	return label + ", user " + c.UserID
+}
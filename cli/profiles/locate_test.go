@@ -0,0 +1,158 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// baseTime is the modification time of the oldest fake profile directory.
+var baseTime = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// makeProfileDir creates dir with one preset in it, last modified minutes after baseTime.
+func makeProfileDir(t *testing.T, dir string, minutes int) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	preset := filepath.Join(dir, "My PLA.json")
+	if err := os.WriteFile(preset, []byte(`{"name":"My PLA"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modified := baseTime.Add(time.Duration(minutes) * time.Minute)
+	for _, path := range []string{preset, dir} {
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// summary is what a test checks of a candidate: everything but the modification time.
+type summary struct {
+	Slicer, Version, UserID string
+	Flatpak                 bool
+	Path                    string
+}
+
+func summarize(candidates []Candidate) []summary {
+	var summaries []summary
+	for _, c := range candidates {
+		summaries = append(summaries, summary{c.Slicer, c.Version, c.UserID, c.Flatpak, c.Path})
+	}
+	return summaries
+}
+
+func TestDiscoverProfileDirsLinux(t *testing.T) {
+	home := t.TempDir()
+	orca := filepath.Join(home, ".config", "OrcaSlicer", "user")
+	creality := filepath.Join(home, ".config", "Creality", "Creality Print")
+	flatpak := filepath.Join(home, ".var", "app", "io.github.softfever.OrcaSlicer", "config", "OrcaSlicer", "user")
+
+	makeProfileDir(t, filepath.Join(orca, "default", "filament", "base"), 10)
+	makeProfileDir(t, filepath.Join(orca, "1881310893", "filament", "base"), 40)
+	makeProfileDir(t, filepath.Join(creality, "6.0", "user", "default", "filament"), 20) // No base directory
+	makeProfileDir(t, filepath.Join(creality, "6.10", "user", "default", "filament", "base"), 20)
+	makeProfileDir(t, filepath.Join(creality, "6.10", "user", "2208034", "filament", "base"), 30)
+	makeProfileDir(t, filepath.Join(flatpak, "default", "filament", "base"), 50)
+	// Ignored: a user without filament presets, a file among the user directories, another app
+	makeProfileDir(t, filepath.Join(orca, "404", "process"), 60)
+	if err := os.WriteFile(filepath.Join(orca, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	makeProfileDir(t, filepath.Join(home, ".var", "app", "org.gimp.GIMP", "config", "GIMP", "user", "default", "filament"), 70)
+
+	got := summarize(DiscoverProfileDirs(DiscoveryRoots{GOOS: "linux", Home: home}))
+	want := []summary{
+		{"OrcaSlicer", "", "default", true, filepath.Join(flatpak, "default", "filament", "base")},
+		{"OrcaSlicer", "", "1881310893", false, filepath.Join(orca, "1881310893", "filament", "base")},
+		{"Creality Print", "6.10", "2208034", false, filepath.Join(creality, "6.10", "user", "2208034", "filament", "base")},
+		// Equally recent: the newer version comes first
+		{"Creality Print", "6.10", "default", false, filepath.Join(creality, "6.10", "user", "default", "filament", "base")},
+		{"Creality Print", "6.0", "default", false, filepath.Join(creality, "6.0", "user", "default", "filament")},
+		{"OrcaSlicer", "", "default", false, filepath.Join(orca, "default", "filament", "base")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoverProfileDirs() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiscoverProfileDirsMacOS(t *testing.T) {
+	home := t.TempDir()
+	support := filepath.Join(home, "Library", "Application Support")
+	makeProfileDir(t, filepath.Join(support, "OrcaSlicer", "user", "default", "filament", "base"), 5)
+	makeProfileDir(t, filepath.Join(support, "OrcaSlicer", "user", "77", "filament", "base"), 15)
+	makeProfileDir(t, filepath.Join(support, "Creality", "Creality Print", "5.1", "user", "default", "filament", "base"), 10)
+	// Linux locations are not searched on macOS
+	makeProfileDir(t, filepath.Join(home, ".config", "OrcaSlicer", "user", "default", "filament", "base"), 20)
+
+	got := summarize(DiscoverProfileDirs(DiscoveryRoots{GOOS: "darwin", Home: home}))
+	want := []summary{
+		{"OrcaSlicer", "", "77", false, filepath.Join(support, "OrcaSlicer", "user", "77", "filament", "base")},
+		{"Creality Print", "5.1", "default", false, filepath.Join(support, "Creality", "Creality Print", "5.1", "user", "default", "filament", "base")},
+		{"OrcaSlicer", "", "default", false, filepath.Join(support, "OrcaSlicer", "user", "default", "filament", "base")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoverProfileDirs() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiscoverProfileDirsWindows(t *testing.T) {
+	home := t.TempDir()
+	appData := filepath.Join(home, "AppData", "Roaming")
+	makeProfileDir(t, filepath.Join(appData, "OrcaSlicer", "user", "default", "filament", "base"), 30)
+	makeProfileDir(t, filepath.Join(appData, "Creality", "Creality Print", "6.0", "user", "default", "filament", "base"), 10)
+	makeProfileDir(t, filepath.Join(appData, "Creality", "Creality Print", "6.0", "user", "3301", "filament", "base"), 20)
+
+	got := summarize(DiscoverProfileDirs(DiscoveryRoots{GOOS: "windows", Home: home, AppData: appData}))
+	want := []summary{
+		{"OrcaSlicer", "", "default", false, filepath.Join(appData, "OrcaSlicer", "user", "default", "filament", "base")},
+		{"Creality Print", "6.0", "3301", false, filepath.Join(appData, "Creality", "Creality Print", "6.0", "user", "3301", "filament", "base")},
+		{"Creality Print", "6.0", "default", false, filepath.Join(appData, "Creality", "Creality Print", "6.0", "user", "default", "filament", "base")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoverProfileDirs() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := DiscoverProfileDirs(DiscoveryRoots{GOOS: "windows", Home: home}); len(got) != 0 {
+		t.Errorf("DiscoverProfileDirs() without APPDATA = %+v, want nothing", got)
+	}
+}
+
+func TestResolveAutoSources(t *testing.T) {
+	home := t.TempDir()
+	newest := filepath.Join(home, ".config", "OrcaSlicer", "user", "42", "filament", "base")
+	makeProfileDir(t, filepath.Join(home, ".config", "OrcaSlicer", "user", "default", "filament", "base"), 1)
+	makeProfileDir(t, newest, 2)
+	roots := DiscoveryRoots{GOOS: "linux", Home: home}
+
+	got, err := ResolveAutoSources([]string{"/profiles/shared", "auto", "AUTO"}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/profiles/shared", newest, newest}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveAutoSources() = %q, want %q", got, want)
+	}
+
+	if _, err := ResolveAutoSources([]string{"auto"}, DiscoveryRoots{GOOS: "linux", Home: t.TempDir()}); err == nil {
+		t.Error("ResolveAutoSources() found a directory in an empty home")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"6.10", "6.2", 1},
+		{"6.0", "6.0.1", -1},
+		{"5.1", "5.1", 0},
+		{"10.0", "9.9", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// colorPattern accepts #rrggbb and #rrggbbaa, with or without the "#".
+var colorPattern = regexp.MustCompile(`^#?([0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// rfidVendorPattern accepts the hexadecimal vendor code of Creality RFID tags.
+var rfidVendorPattern = regexp.MustCompile(`^[0-9a-fA-F]{1,8}$`)
+
+// NozzleDiameters lists nozzle sizes in millimetres. Notes may give them as numbers or strings.
+type NozzleDiameters []string
+
+// UnmarshalJSON accepts [0.4, "0.6"] and a single value such as 0.4.
+func (d *NozzleDiameters) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		var single interface{}
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		values = []interface{}{single}
+	}
+
+	*d = nil
+	for _, value := range values {
+		var text string
+		switch v := value.(type) {
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			text = strings.TrimSpace(v)
+		default:
+			return fmt.Errorf("nozzle diameter %v is not a number", value)
+		}
+		diameter, err := strconv.ParseFloat(text, 64)
+		if err != nil || diameter <= 0 {
+			return fmt.Errorf("nozzle diameter %q is not a positive number", text)
+		}
+		*d = append(*d, strconv.FormatFloat(diameter, 'f', -1, 64))
+	}
+	return nil
+}
+
+// NozzleOverrides maps a nozzle diameter to the settings that differ for it, e.g.
+// {"0.6": {"filament_max_volumetric_speed": 18}}. Values may be numbers or strings.
+type NozzleOverrides map[string]map[string]string
+
+// UnmarshalJSON normalizes the diameters and formats number values as text.
+func (o *NozzleOverrides) UnmarshalJSON(data []byte) error {
+	var raw map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*o = make(NozzleOverrides)
+	for diameterText, settings := range raw {
+		var diameter NozzleDiameters
+		if err := diameter.UnmarshalJSON([]byte(strconv.Quote(diameterText))); err != nil {
+			return err
+		}
+		normalized := make(map[string]string)
+		for key, value := range settings {
+			text, _ := scalarString(value)
+			normalized[key] = text
+		}
+		(*o)[diameter[0]] = normalized
+	}
+	return nil
+}
+
+// knownNoteFields returns the JSON names of the FilamentNotes fields.
+func knownNoteFields() []string {
+	return []string{"id", "vendor", "type", "name", "colors", "rank", "dryingTemp", "dryingTime",
+		"weightPerMeter", "nozzleDiameters", "nozzleOverrides", "rfidVendorId", "printers", "template"}
+}
+
+// ParseNotes parses the sync metadata JSON object kept in filament_notes. Unknown fields
+// (usually typos) are an error, as are values outside their valid range.
+func ParseNotes(text string) (*FilamentNotes, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return nil, fmt.Errorf("not a JSON object: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, name := range knownNoteFields() {
+		known[name] = true
+	}
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown field(s) %s; supported fields are %s",
+			strings.Join(unknown, ", "), strings.Join(knownNoteFields(), ", "))
+	}
+
+	var notes FilamentNotes
+	if err := json.Unmarshal([]byte(text), &notes); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	if err := notes.Validate(); err != nil {
+		return nil, err
+	}
+	return &notes, nil
+}
+
+// Validate checks the optional fields and normalizes colors to lowercase "#rrggbb".
+func (n *FilamentNotes) Validate() error {
+	if strings.TrimSpace(n.ID) == "" {
+		return fmt.Errorf("missing 'id'")
+	}
+	for i, color := range n.Colors {
+		if !colorPattern.MatchString(color) {
+			return fmt.Errorf("colors: %q is not a #rrggbb color", color)
+		}
+		n.Colors[i] = "#" + strings.ToLower(strings.TrimPrefix(color, "#"))
+	}
+	if n.Rank < 0 {
+		return fmt.Errorf("rank must not be negative, got %d", n.Rank)
+	}
+	if n.DryingTemp < 0 || n.DryingTemp > 150 {
+		return fmt.Errorf("dryingTemp must be between 0 and 150 °C, got %d", n.DryingTemp)
+	}
+	if n.DryingTime < 0 || n.DryingTime > 72 {
+		return fmt.Errorf("dryingTime must be between 0 and 72 hours, got %d", n.DryingTime)
+	}
+	if n.WeightPerMeter < 0 {
+		return fmt.Errorf("weightPerMeter must not be negative, got %v", n.WeightPerMeter)
+	}
+	if n.RFIDVendorID != "" && !rfidVendorPattern.MatchString(n.RFIDVendorID) {
+		return fmt.Errorf("rfidVendorId %q is not a hexadecimal vendor code", n.RFIDVendorID)
+	}
+	for _, printer := range n.Printers {
+		if strings.TrimSpace(printer) == "" {
+			return fmt.Errorf("printers must not contain empty names")
+		}
+	}
+	return nil
+}
+
+// TargetsPrinter reports whether the profile is meant for the printer with the given registry
+// name or printerIntName. Profiles without a printers list target every printer.
+func (n *FilamentNotes) TargetsPrinter(names ...string) bool {
+	if len(n.Printers) == 0 {
+		return true
+	}
+	for _, printer := range n.Printers {
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(printer), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ErrNoNotesMetadata is returned by ExtractNotes when the notes carry no sync metadata at all.
+var ErrNoNotesMetadata = errors.New("no sync metadata found")
+
+// notesTag marks a metadata block inside free-text notes: <filament-sync>...</filament-sync>.
+var notesTag = regexp.MustCompile(`(?s)<filament-sync>(.*?)</filament-sync>`)
+
+// fencedBlock matches a Markdown code fence with an optional language tag.
+var fencedBlock = regexp.MustCompile("(?s)```[ \\t]*([A-Za-z0-9_-]*)[ \\t]*\\r?\\n(.*?)```")
+
+// keyValueLine matches "key: value" and "key = value" lines.
+var keyValueLine = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z ]*?)\s*[:=]\s*(.*?)\s*$`)
+
+// blankLine separates the paragraphs of free-text notes.
+var blankLine = regexp.MustCompile(`\n[ \t]*\r?\n`)
+
+// ExtractNotes finds the sync metadata in the free text of filament_notes, so the notes can
+// also hold the user's own remarks. It looks for, in order:
+//   - notes that are a single JSON object (the original format)
+//   - a <filament-sync>...</filament-sync> block
+//   - a ``` fenced block holding a JSON object or key: value lines
+//   - a paragraph of nothing but key: value lines for the note fields, anywhere in the text
+//   - a JSON object with an "id" anywhere in the text
+func ExtractNotes(text string) (*FilamentNotes, error) {
+	trimmed := strings.TrimSpace(strings.Trim(strings.TrimSpace(text), `"`))
+	if trimmed == "" {
+		return nil, ErrNoNotesMetadata
+	}
+	if strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}") {
+		return ParseNotes(trimmed)
+	}
+
+	if match := notesTag.FindStringSubmatch(text); match != nil {
+		return parseNotesBlock(match[1])
+	}
+
+	for _, match := range fencedBlock.FindAllStringSubmatch(text, -1) {
+		lang, content := strings.ToLower(match[1]), match[2]
+		if lang == "filament-sync" {
+			return parseNotesBlock(content)
+		}
+		// Other fences may hold unrelated snippets; only take one that carries an ID
+		if notes, err := parseNotesBlock(content); err != ErrNoNotesMetadata {
+			return notes, err
+		}
+	}
+
+	// Outside a block, prose such as "Type: great for vases" must not be taken for metadata
+	for _, paragraph := range blankLine.Split(text, -1) {
+		if notes, err := parseKeyValueNotes(paragraph, true); err != ErrNoNotesMetadata {
+			return notes, err
+		}
+	}
+
+	if object := embeddedJSONObject(text); object != "" {
+		return ParseNotes(object)
+	}
+	return nil, ErrNoNotesMetadata
+}
+
+// parseNotesBlock parses a metadata block holding either a JSON object or key: value lines.
+func parseNotesBlock(block string) (*FilamentNotes, error) {
+	block = strings.TrimSpace(block)
+	if strings.HasPrefix(block, "{") {
+		return ParseNotes(block)
+	}
+	return parseKeyValueNotes(block, false)
+}
+
+// parseKeyValueNotes collects "key: value" lines whose key is a note field and ignores all
+// other lines, or, when strict, finds no metadata in text that has other lines. List fields
+// take comma-separated values and nozzleOverrides a JSON object on one line.
+func parseKeyValueNotes(text string, strict bool) (*FilamentNotes, error) {
+	canonical := make(map[string]string)
+	for _, name := range knownNoteFields() {
+		canonical[strings.ToLower(name)] = name
+	}
+
+	fields := make(map[string]interface{})
+	for _, line := range strings.Split(text, "\n") {
+		match := keyValueLine.FindStringSubmatch(line)
+		name, ok := "", false
+		if match != nil {
+			name, ok = canonical[strings.ToLower(strings.ReplaceAll(match[1], " ", ""))]
+		}
+		if !ok {
+			if strict && strings.TrimSpace(line) != "" {
+				return nil, ErrNoNotesMetadata
+			}
+			continue // Prose such as "Store: in a dry box"
+		}
+		value := strings.Trim(match[2], `"`)
+
+		switch name {
+		case "colors", "nozzleDiameters", "printers":
+			var list []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			fields[name] = list
+		case "rank", "dryingTemp", "dryingTime":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a whole number", name, value)
+			}
+			fields[name] = number
+		case "weightPerMeter":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", name, value)
+			}
+			fields[name] = number
+		case "nozzleOverrides":
+			if !strings.HasPrefix(value, "{") || !json.Valid([]byte(value)) {
+				return nil, fmt.Errorf(`%s: %q is not a JSON object such as {"0.6": {"filament_max_volumetric_speed": 18}}`, name, value)
+			}
+			fields[name] = json.RawMessage(value)
+		default:
+			fields[name] = value
+		}
+	}
+	if _, ok := fields["id"]; !ok {
+		return nil, ErrNoNotesMetadata
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode note fields: %w", err)
+	}
+	return ParseNotes(string(data))
+}
+
+// embeddedJSONObject returns the first balanced {...} in text that mentions "id", or "".
+func embeddedJSONObject(text string) string {
+	for start := strings.Index(text, "{"); start >= 0; {
+		depth := 0
+		inString := false
+		for i := start; i < len(text); i++ {
+			switch c := text[i]; {
+			case inString && c == '\\':
+				i++ // Skip the escaped character
+			case c == '"':
+				inString = !inString
+			case !inString && c == '{':
+				depth++
+			case !inString && c == '}':
+				depth--
+				if depth == 0 {
+					if object := text[start : i+1]; strings.Contains(object, `"id"`) {
+						return object
+					}
+					i = len(text)
+				}
+			}
+		}
+		next := strings.Index(text[start+1:], "{")
+		if next < 0 {
+			break
+		}
+		start += next + 1
+	}
+	return ""
+}
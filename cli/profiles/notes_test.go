@@ -0,0 +1,105 @@
+package profiles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractNotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes string
+		want  FilamentNotes
+	}{
+		{
+			name:  "JSON object",
+			notes: `{"id":"90001","vendor":"Acme","type":"PLA","name":"Acme PLA"}`,
+			want:  FilamentNotes{ID: "90001", Vendor: "Acme", Type: "PLA", Name: "Acme PLA"},
+		},
+		{
+			name: "prose around a fenced block",
+			notes: "Bought at the Acme store. Type: great for vases.\n" +
+				"Colors: red and black\n\n" +
+				"```filament-sync\nid: 90002\nvendor: Acme\ntype: PETG\nname: Acme PETG\ncolors: #FF0000\n```\n" +
+				"Dry at 65 °C before use.",
+			want: FilamentNotes{ID: "90002", Vendor: "Acme", Type: "PETG", Name: "Acme PETG", Colors: []string{"#ff0000"}},
+		},
+		{
+			name:  "fenced JSON without a language",
+			notes: "Notes first.\n```\n{\"id\": \"90003\", \"type\": \"ABS\"}\n```\n",
+			want:  FilamentNotes{ID: "90003", Type: "ABS"},
+		},
+		{
+			name: "prose around a tagged block",
+			notes: "Rank: my favourite\n" +
+				"<filament-sync>\nid: 90004\ntype: ASA\nrank: 2\nStore in a dry box\n</filament-sync>\n" +
+				"Printers: all of them",
+			want: FilamentNotes{ID: "90004", Type: "ASA", Rank: 2},
+		},
+		{
+			name:  "bare lines",
+			notes: "id: 90005\nvendor: Acme\ntype: TPU\ndrying temp: 50\nnozzle diameters: 0.4, 0.6",
+			want:  FilamentNotes{ID: "90005", Vendor: "Acme", Type: "TPU", DryingTemp: 50, NozzleDiameters: NozzleDiameters{"0.4", "0.6"}},
+		},
+		{
+			name: "bare lines in their own paragraph",
+			notes: "Type: great for vases, prints at 210.\nColors: red\n\n" +
+				"id = 90006\ntype = PLA\n\n" +
+				"Where to buy: acme.example",
+			want: FilamentNotes{ID: "90006", Type: "PLA"},
+		},
+		{
+			name:  "JSON object in prose",
+			notes: `Sync data: {"id":"90007","type":"PC"} keep this line.`,
+			want:  FilamentNotes{ID: "90007", Type: "PC"},
+		},
+		{
+			name:  "key: value lines in any case with an object field",
+			notes: "```filament-sync\nID: 90008\nDrying Temp: 55\nnozzle overrides: {\"0.6\": {\"filament_max_volumetric_speed\": 18}}\n```",
+			want:  FilamentNotes{ID: "90008", DryingTemp: 55, NozzleOverrides: NozzleOverrides{"0.6": {"filament_max_volumetric_speed": "18"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, err := ExtractNotes(tt.notes)
+			if err != nil {
+				t.Fatalf("ExtractNotes() error: %v", err)
+			}
+			if !reflect.DeepEqual(*notes, tt.want) {
+				t.Errorf("ExtractNotes() = %+v, want %+v", *notes, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractNotesIgnoresProse(t *testing.T) {
+	for _, notes := range []string{
+		"",
+		"I like it.\nColors: red\nid: 1",
+		"Colors: red\nid: 1\nGreat for vases.",
+		"Type: great for vases\nStore: in a dry box",
+		"```gcode\nM104 S210\n```",
+	} {
+		if got, err := ExtractNotes(notes); err != ErrNoNotesMetadata {
+			t.Errorf("ExtractNotes(%q) = %+v, %v, want no metadata", notes, got, err)
+		}
+	}
+}
+
+func TestExtractNotesInvalidMetadata(t *testing.T) {
+	tests := []struct {
+		notes, want string
+	}{
+		{"```filament-sync\nid: 90010\ncolors: red\n```", "not a #rrggbb color"},
+		{"<filament-sync>{\"id\":\"90011\",\"colour\":\"#ff0000\"}</filament-sync>", "unknown field(s) colour"},
+		{"id: 90012\nrank: first", "not a whole number"},
+		{"id: 90013\nnozzleOverrides: 0.6", "nozzleOverrides: \"0.6\" is not a JSON object"},
+		{"id: 90014\nnozzleOverrides: {\"0.6\": 18}", "invalid value"},
+	}
+	for _, tt := range tests {
+		if _, err := ExtractNotes(tt.notes); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ExtractNotes(%q) error = %v, want %q", tt.notes, err, tt.want)
+		}
+	}
+}
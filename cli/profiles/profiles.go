@@ -6,15 +6,28 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 // FilamentNotes represents the JSON structure expected within the "filament_notes" field of the slicer profile.
+// Only the ID is required; the optional fields fill in what the slicer profile cannot express.
 type FilamentNotes struct {
 	ID     string `json:"id"`
 	Vendor string `json:"vendor"`
 	Type   string `json:"type"`
 	Name   string `json:"name"`
+
+	Colors          []string        `json:"colors,omitempty"`          // Spool colors as #rrggbb
+	Rank            int             `json:"rank,omitempty"`            // Sort order on the printer, higher first
+	DryingTemp      int             `json:"dryingTemp,omitempty"`      // °C
+	DryingTime      int             `json:"dryingTime,omitempty"`      // Hours
+	WeightPerMeter  float64         `json:"weightPerMeter,omitempty"`  // Grams
+	NozzleDiameters NozzleDiameters `json:"nozzleDiameters,omitempty"` // Millimetres, default from compatible_printers or 0.4
+	NozzleOverrides NozzleOverrides `json:"nozzleOverrides,omitempty"` // Settings that differ per nozzle diameter
+	RFIDVendorID    string          `json:"rfidVendorId,omitempty"`    // Vendor code of the RFID tags, metadata only
+	Printers        []string        `json:"printers,omitempty"`        // Printer models (--model names) the profile is for
+	Template        string          `json:"template,omitempty"`        // Stock filament ID to take unset settings from, or "none"
 }
 
 // SlicerFilamentProfile represents the structure of a filament profile JSON from OrcaSlicer/Creality Print.
@@ -23,6 +36,8 @@ type SlicerFilamentProfile struct {
 	Version       string                 `json:"version"`
 	FilamentNotes []string               `json:"filament_notes"` // This contains the embedded JSON string
 	RawData       map[string]interface{} `json:"-"`              // Store raw data for dynamic access
+	Path          string                 `json:"-"`              // File the profile was read from
+	Defaulted     map[string]bool        `json:"-"`              // Keys Resolve took from the built-in defaults
 }
 
 // UnmarshalJSON custom unmarshaler to capture all raw data.
@@ -43,7 +58,13 @@ func (s *SlicerFilamentProfile) UnmarshalJSON(data []byte) error {
 }
 
 // ReadSlicerProfile reads a JSON file and unmarshals it into a SlicerFilamentProfile.
+// PrusaSlicer/SuperSlicer .ini presets and Cura .fdm_material files are read with their keys
+// mapped onto OrcaSlicer's.
 func ReadSlicerProfile(filePath string) (*SlicerFilamentProfile, error) {
+	if profile, ok, err := readForeignProfile(filePath); ok {
+		return profile, err
+	}
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
@@ -53,56 +74,84 @@ func ReadSlicerProfile(filePath string) (*SlicerFilamentProfile, error) {
 	if err := json.Unmarshal(data, &profile); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON from %s: %w", filePath, err)
 	}
+	profile.Path = filePath
 	return &profile, nil
 }
 
-// NormalizeSlicerProfile processes the raw SlicerFilamentProfile.
-func NormalizeSlicerProfile(slicerProfile *SlicerFilamentProfile) (map[string]string, *FilamentNotes, error) {
+// ReadRawProfile reads the raw data of a slicer profile in any supported format.
+func ReadRawProfile(filePath string) (map[string]interface{}, error) {
+	if profile, ok, err := readForeignProfile(filePath); ok {
+		if err != nil {
+			return nil, err
+		}
+		return profile.RawData, nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return raw, nil
+}
+
+// readForeignProfile reads presets of slicers that do not use OrcaSlicer's JSON format. ok is
+// false for any other file.
+func readForeignProfile(filePath string) (profile *SlicerFilamentProfile, ok bool, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case iniExtension:
+		profile, err = readIniProfile(filePath)
+	case curaExtension:
+		profile, err = readCuraProfile(filePath)
+	default:
+		return nil, false, nil
+	}
+	return profile, true, err
+}
+
+// isProfileFile reports whether the file name has the extension of a supported profile format.
+func isProfileFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", iniExtension, curaExtension:
+		return true
+	}
+	return false
+}
+
+// NormalizeSlicerProfile processes the raw SlicerFilamentProfile. A nil identity reads the
+// metadata from filament_notes.
+func NormalizeSlicerProfile(slicerProfile *SlicerFilamentProfile, identity *Identity) (map[string]string, *FilamentNotes, error) {
 	normalizedData := make(map[string]string)
-	var notes *FilamentNotes
 
-	// Process raw data to flatten array fields and convert to string
-	for key, value := range slicerProfile.RawData {
+	// Process raw data to serialize array fields and convert to string. Keys are sorted so
+	// warnings come out in a stable order.
+	keys := make([]string, 0, len(slicerProfile.RawData))
+	for key := range slicerProfile.RawData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
 		if key == "name" || key == "version" || key == "filament_notes" {
 			continue
 		}
 
-		if arr, ok := value.([]interface{}); ok && len(arr) > 0 {
-			if strVal, ok := arr[0].(string); ok {
-				normalizedData[key] = strVal
-			} else if numVal, ok := arr[0].(float64); ok {
-				normalizedData[key] = fmt.Sprintf("%v", numVal)
-			} else if boolVal, ok := value.([]interface{})[0].(bool); ok {
-				normalizedData[key] = fmt.Sprintf("%v", boolVal)
-			} else {
-				normalizedData[key] = fmt.Sprintf("%v", arr[0])
-			}
-		} else {
-			if strVal, ok := value.(string); ok {
-				normalizedData[key] = strVal
-			} else if numVal, ok := value.(float64); ok {
-				normalizedData[key] = fmt.Sprintf("%v", numVal)
-			} else if boolVal, ok := value.(bool); ok {
-				normalizedData[key] = fmt.Sprintf("%v", boolVal)
-			} else {
-				normalizedData[key] = fmt.Sprintf("%v", value)
-			}
+		value, warnings := SerializeSetting(key, slicerProfile.RawData[key])
+		for _, warning := range warnings {
+			log.Printf("Warning: %s: %s", slicerProfile.Path, warning)
 		}
+		normalizedData[key] = value
 	}
 
 	normalizedData["name"] = slicerProfile.Name
 	normalizedData["version"] = slicerProfile.Version
 
-	if len(slicerProfile.FilamentNotes) > 0 && slicerProfile.FilamentNotes[0] != "" {
-		var parsedNotes FilamentNotes
-		noteString := strings.Trim(slicerProfile.FilamentNotes[0], "\"")
-		err := json.Unmarshal([]byte(noteString), &parsedNotes)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse filament_notes JSON: %w, raw note: %s", err, noteString)
-		}
-		notes = &parsedNotes
-	} else {
-		return nil, nil, fmt.Errorf("filament_notes is missing or empty in the profile")
+	// The identity mode decides whether the metadata comes from filament_notes or the preset itself
+	notes, err := identity.Notes(slicerProfile.RawData, slicerProfile.Path)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	normalizedData["filament_notes.id"] = notes.ID
@@ -114,53 +163,110 @@ func NormalizeSlicerProfile(slicerProfile *SlicerFilamentProfile) (map[string]st
 }
 
 // LoadCustomProfiles reads JSON files from the given directory and filters them.
-func LoadCustomProfiles(dir string) ([]string, error) {
-	var profilePaths []string
-
-	files, err := os.ReadDir(dir)
+// With recursive set, sub-directories are scanned too.
+func LoadCustomProfiles(dir string, recursive bool, identity *Identity) ([]string, error) {
+	files, err := loadCustomProfiles(dir, recursive, identity)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+		return nil, err
 	}
 
+	profilePaths := make([]string, 0, len(files))
 	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
-			continue
+		profilePaths = append(profilePaths, file.Path)
+	}
+	return profilePaths, nil
+}
+
+// profileFile is a custom profile found while scanning, with the ID from its notes.
+type profileFile struct {
+	Path string
+	ID   string
+}
+
+// loadCustomProfiles lists the profiles in dir that carry sync metadata. Files nearer to dir
+// come first, then alphabetical order, which is the precedence used for duplicate IDs.
+func loadCustomProfiles(dir string, recursive bool, identity *Identity) ([]profileFile, error) {
+	filePaths, err := profileFilesIn(dir, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []profileFile
+	for _, filePath := range filePaths {
+		displayName, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			displayName = filepath.Base(filePath)
 		}
+		if id, ok := checkCustomProfile(filePath, displayName, identity); ok {
+			profiles = append(profiles, profileFile{Path: filePath, ID: id})
+		}
+	}
+	return profiles, nil
+}
 
-		filePath := filepath.Join(dir, file.Name())
-		data, err := os.ReadFile(filePath)
+// ListProfileFiles returns every profile file in dirs, whether or not it carries sync metadata,
+// in the same order CollectProfiles scans them.
+func ListProfileFiles(dirs []string, recursive bool) ([]string, error) {
+	var filePaths []string
+	for _, dir := range dirs {
+		files, err := profileFilesIn(dir, recursive)
 		if err != nil {
-			log.Printf("Skipping %s: failed to read file: %v", file.Name(), err)
-			continue
+			return nil, err
 		}
+		filePaths = append(filePaths, files...)
+	}
+	return filePaths, nil
+}
+
+// profileFilesIn lists the profile files in dir breadth-first, so files nearer to dir come first,
+// then alphabetical order. Hidden sub-directories are skipped.
+func profileFilesIn(dir string, recursive bool) ([]string, error) {
+	var filePaths []string
 
-		var rawProfile map[string]interface{}
-		if err := json.Unmarshal(data, &rawProfile); err != nil {
-			log.Printf("Skipping %s: failed to unmarshal JSON: %v", file.Name(), err)
+	pending := []string{dir}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		files, err := os.ReadDir(current)
+		if err != nil {
+			if current == dir {
+				return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+			}
+			log.Printf("Skipping directory %s: %v", current, err)
 			continue
 		}
 
-		if notesVal, ok := rawProfile["filament_notes"]; ok {
-			if notesArr, isArray := notesVal.([]interface{}); isArray && len(notesArr) > 0 {
-				if noteStr, isString := notesArr[0].(string); isString && strings.TrimSpace(noteStr) != "" && strings.Contains(noteStr, `"id":`) {
-					var tempNotes FilamentNotes
-					unquotedNoteStr := strings.Trim(noteStr, `"`)
-
-					if err := json.Unmarshal([]byte(unquotedNoteStr), &tempNotes); err == nil && tempNotes.ID != "" {
-						profilePaths = append(profilePaths, filePath)
-					} else {
-						log.Printf("Ignoring %s: filament_notes invalid or missing 'id'. Inner content: %s", file.Name(), unquotedNoteStr)
-					}
-				} else {
-					log.Printf("Ignoring %s: filament_notes empty/invalid", file.Name())
+		for _, file := range files {
+			filePath := filepath.Join(current, file.Name())
+			if file.IsDir() {
+				if recursive && !strings.HasPrefix(file.Name(), ".") {
+					pending = append(pending, filePath)
 				}
-			} else {
-				log.Printf("Ignoring %s: filament_notes missing/invalid format", file.Name())
+				continue
+			}
+			if isProfileFile(file.Name()) {
+				filePaths = append(filePaths, filePath)
 			}
-		} else {
-			log.Printf("Ignoring %s: missing required 'filament_notes' field", file.Name())
 		}
 	}
 
-	return profilePaths, nil
+	return filePaths, nil
+}
+
+// checkCustomProfile reports whether the file is a slicer profile that carries an ID under the
+// identity mode, logging why it is ignored otherwise.
+func checkCustomProfile(filePath, displayName string, identity *Identity) (string, bool) {
+	rawProfile, err := ReadRawProfile(filePath)
+	if err != nil {
+		log.Printf("Skipping %s: %v", displayName, err)
+		return "", false
+	}
+
+	notes, err := identity.Notes(rawProfile, filePath)
+	if err != nil {
+		log.Printf("Ignoring %s: %v", displayName, err)
+		return "", false
+	}
+	return notes.ID, true
 }
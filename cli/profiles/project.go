@@ -0,0 +1,110 @@
+package profiles
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// projectSettingsFile is where OrcaSlicer and Creality Print store the full slicing settings
+// inside a 3MF project archive.
+const projectSettingsFile = "Metadata/project_settings.config"
+
+// ReadProjectProfiles extracts the filament presets of a 3MF project, one per filament slot.
+func ReadProjectProfiles(path string) ([]*SlicerFilamentProfile, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open 3MF project %s: %w", path, err)
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != projectSettingsFile {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in %s: %w", projectSettingsFile, path, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", projectSettingsFile, path, err)
+		}
+
+		var settings map[string]interface{}
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", projectSettingsFile, path, err)
+		}
+		return splitFilamentSlots(settings, path, nil)
+	}
+	return nil, fmt.Errorf("%s has no %s; only projects saved by OrcaSlicer or Creality Print carry filament settings", path, projectSettingsFile)
+}
+
+// splitFilamentSlots turns the flattened settings of a slicing project into one preset per
+// filament slot. Per-filament settings are arrays with one value per slot; element i belongs
+// to slot i. Slots that use the same preset yield it only once, and slots marked false in used
+// are left out (nil keeps every slot). Each preset's Path is source followed by "#<slot>",
+// counting from 1.
+func splitFilamentSlots(settings map[string]interface{}, source string, used []bool) ([]*SlicerFilamentProfile, error) {
+	names, ok := settings["filament_settings_id"].([]interface{})
+	if !ok || len(names) == 0 {
+		return nil, fmt.Errorf("%s holds no filament settings", source)
+	}
+
+	var defaults map[string]interface{}
+	if err := json.Unmarshal(defaultsJSON, &defaults); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded filament defaults: %w", err)
+	}
+
+	// The project's printer preset names the nozzle, e.g. "Creality K2 Plus 0.4 nozzle"
+	printerPreset := firstString(settings["printer_settings_id"])
+
+	var presets []*SlicerFilamentProfile
+	seen := make(map[string]bool)
+	for slot := range names {
+		name, _ := names[slot].(string)
+		if seen[name] || (slot < len(used) && !used[slot]) {
+			continue
+		}
+		seen[name] = true
+
+		raw := map[string]interface{}{"name": name}
+		for key, value := range settings {
+			if !isFilamentSetting(key, defaults) {
+				continue
+			}
+			if values, ok := value.([]interface{}); ok && len(values) == len(names) {
+				raw[key] = []interface{}{values[slot]}
+			}
+		}
+		// OrcaSlicer keeps the filament_id of each slot in a separate list
+		if ids, ok := settings["filament_ids"].([]interface{}); ok && len(ids) == len(names) {
+			raw["filament_id"] = ids[slot]
+		}
+		if printerPreset != "" {
+			raw["compatible_printers"] = []interface{}{printerPreset}
+		}
+
+		presets = append(presets, &SlicerFilamentProfile{
+			Name:    name,
+			RawData: raw,
+			Path:    fmt.Sprintf("%s#%d", source, slot+1),
+		})
+	}
+	return presets, nil
+}
+
+// isFilamentSetting reports whether a project setting belongs to the filament presets rather
+// than to the print or printer preset. The compatibility lists in a project are the print's.
+func isFilamentSetting(key string, defaults map[string]interface{}) bool {
+	if strings.HasPrefix(key, "compatible_print") || key == "filament_settings_id" || key == "filament_ids" {
+		return false
+	}
+	if _, ok := defaults[key]; ok {
+		return true
+	}
+	return strings.HasPrefix(key, "filament_")
+}
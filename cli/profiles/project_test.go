@@ -0,0 +1,69 @@
+package profiles
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeProject zips the fixture project settings into a 3MF project in a temporary directory.
+func writeProject(t *testing.T) string {
+	t.Helper()
+	settings, err := os.ReadFile(filepath.Join("testdata", "project_settings.config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "benchy.3mf")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for name, content := range map[string][]byte{
+		"3D/3dmodel.model":  []byte("<model/>"),
+		projectSettingsFile: settings,
+	} {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadProjectProfiles(t *testing.T) {
+	path := writeProject(t)
+	presets, err := ReadProjectProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both slots use the same preset, so it is read once
+	want := []*SlicerFilamentProfile{{
+		Name: "Acme PLA",
+		Path: path + "#1",
+		RawData: map[string]interface{}{
+			"name":                 "Acme PLA",
+			"filament_id":          "GFL99",
+			"filament_start_gcode": []interface{}{"; filament start gcode\nM106 P3 S0"},
+			"filament_type":        []interface{}{"PLA"},
+			"nozzle_temperature":   []interface{}{"210"},
+			"compatible_printers":  []interface{}{"Creality K2 Plus 0.6 nozzle"},
+		},
+	}}
+	if !reflect.DeepEqual(presets, want) {
+		for _, preset := range presets {
+			t.Logf("%s %s: %#v", preset.Name, preset.Path, preset.RawData)
+		}
+		t.Errorf("ReadProjectProfiles() does not match the fixture")
+	}
+}
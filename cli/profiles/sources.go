@@ -0,0 +1,97 @@
+package profiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandSources resolves profile source arguments into directories. A leading "~" is
+// expanded to the home directory and glob patterns (e.g. user/*/filament) to every matching
+// directory. Order is preserved, since it is the precedence order.
+func ExpandSources(sources []string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+
+	for _, source := range sources {
+		pattern := ExpandHome(source)
+
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			globbed, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid profile path pattern %s: %w", source, err)
+			}
+			if len(globbed) == 0 {
+				return nil, fmt.Errorf("profile path pattern matches nothing: %s", source)
+			}
+			matches = globbed
+		}
+
+		for _, match := range matches {
+			stat, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("profile path does not exist: %s", match)
+			}
+			if !stat.IsDir() {
+				if len(matches) > 1 {
+					continue // Patterns may also match files next to the wanted directories
+				}
+				return nil, fmt.Errorf("profile path is not a directory: %s", match)
+			}
+			if !seen[match] {
+				seen[match] = true
+				dirs = append(dirs, match)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// Duplicate is a profile whose ID is already provided by an earlier profile.
+type Duplicate struct {
+	ID     string
+	Path   string // The profile that is not used
+	Winner string // The profile providing the ID
+}
+
+// CollectProfiles scans every source directory and returns the custom profile paths. When the
+// same ID appears more than once, the first occurrence wins: earlier sources before later ones,
+// and within a source, files nearer to the source directory before deeper ones, then
+// alphabetical order. The profiles that lose are returned as duplicates.
+func CollectProfiles(dirs []string, recursive bool, identity *Identity) ([]string, []Duplicate, error) {
+	var profilePaths []string
+	var duplicates []Duplicate
+	winners := make(map[string]string) // ID to the path providing it
+
+	for _, dir := range dirs {
+		files, err := loadCustomProfiles(dir, recursive, identity)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, file := range files {
+			if winner, ok := winners[file.ID]; ok {
+				duplicates = append(duplicates, Duplicate{ID: file.ID, Path: file.Path, Winner: winner})
+				continue
+			}
+			winners[file.ID] = file.Path
+			profilePaths = append(profilePaths, file.Path)
+		}
+	}
+	return profilePaths, duplicates, nil
+}
+
+// ExpandHome replaces a leading "~" with the user's home directory, for paths quoted in
+// post-processing commands where the shell does not expand it.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,20 @@
+; HEADER_BLOCK_START
+; generated by OrcaSlicer 2.2.0
+; HEADER_BLOCK_END
+G28
+G1 X10 Y10 F3000
+M104 S210
+; filament used [mm] = 1520.33, 0.00, 85.10
+; filament used [g] = 4.53, 0.00, 0.25
+; CONFIG_BLOCK_START
+; compatible_printers_condition = "printer_notes=~/.*K2.*/"
+; default_filament_colour = "#FF0000";"#00FF00";"#0000FF"
+; filament_ids = GFL99;GFG99;P1d2b3a4
+; filament_settings_id = "Acme PLA";"Acme PETG";"Acme PLA Silk"
+; filament_start_gcode = "; filament start gcode\nM106 P3 S0";"; PETG\nM106 P3 S50";"; silk; slow\nM220 S90"
+; filament_type = PLA;PETG;PLA
+; machine_start_gcode = "G28 ; home\nM104 S[nozzle_temperature_initial_layer]"
+; machine_end_gcode = "M104 S0\nM140 S0
+; nozzle_temperature = 210,240,215
+; printer_settings_id = "Creality K2 Plus 0.4 nozzle"
+; CONFIG_BLOCK_END
@@ -0,0 +1,90 @@
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Separators used when a vector setting is stored as one string in the Creality database.
+const (
+	listSeparator    = "," // Lists of names, e.g. compatible_printers in the stock database
+	numberSeparator  = "," // Per-extruder numbers and booleans, as in slicer config exports
+	stringsSeparator = ";" // Per-extruder strings, as in slicer config exports
+)
+
+// listSettings are vectors of names rather than per-extruder values.
+var listSettings = map[string]bool{
+	"compatible_printers":       true,
+	"compatible_prints":         true,
+	"filament_extruder_variant": true,
+}
+
+// SerializeSetting turns a slicer setting into the string stored in the Creality database.
+// Vectors are joined with the separator the database uses for the setting, keeping one element
+// per extruder even when they are all equal. The warnings describe information that could not
+// be kept.
+func SerializeSetting(key string, value interface{}) (string, []string) {
+	values, isVector := value.([]interface{})
+	if !isVector {
+		text, _ := scalarString(value)
+		return text, nil
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	var warnings []string
+	texts := make([]string, len(values))
+	allNumbers := true
+	for i, element := range values {
+		text, isNumber := scalarString(element)
+		if element == nil {
+			warnings = append(warnings, fmt.Sprintf("element %d of %s is null and is stored as empty", i+1, key))
+		}
+		// Slicers store numbers as strings and use "nil" for "not set"; neither makes it a string vector
+		if _, err := strconv.ParseFloat(text, 64); err == nil || element == nil || text == "nil" {
+			isNumber = true
+		}
+		allNumbers = allNumbers && isNumber
+		texts[i] = text
+	}
+
+	separator := stringsSeparator
+	switch {
+	case listSettings[key]:
+		separator = listSeparator
+	case allNumbers:
+		separator = numberSeparator
+	}
+
+	// A separator inside an element cannot be told apart from the one between elements
+	for i, text := range texts {
+		if strings.Contains(text, separator) {
+			warnings = append(warnings, fmt.Sprintf("element %d of %s contains the separator %q; the elements cannot be split apart again", i+1, key, separator))
+		}
+	}
+	return strings.Join(texts, separator), warnings
+}
+
+// scalarString formats a JSON value as text and reports whether it is a number or boolean.
+// Nested arrays and objects are kept as JSON.
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v), false
+		}
+		return string(data), false
+	}
+}
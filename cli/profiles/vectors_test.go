@@ -0,0 +1,63 @@
+package profiles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSerializeSetting(t *testing.T) {
+	tests := []struct {
+		key      string
+		value    interface{}
+		want     string
+		warnings int
+	}{
+		{"nozzle_temperature", "220", "220", 0},
+		{"nozzle_temperature", []interface{}{"220"}, "220", 0},
+		{"nozzle_temperature", []interface{}{"220", "220"}, "220,220", 0},
+		{"nozzle_temperature", []interface{}{"220", "230"}, "220,230", 0},
+		{"filament_retraction_length", []interface{}{"nil", "0.8"}, "nil,0.8", 0},
+		{"filament_flow_ratio", []interface{}{0.98, 0.98}, "0.98,0.98", 0},
+		{"filament_type", []interface{}{"PLA", "PLA"}, "PLA;PLA", 0},
+		{"filament_start_gcode", []interface{}{"; start\nM104 S210", "M104 S220"}, "; start\nM104 S210;M104 S220", 1},
+		{"compatible_printers", []interface{}{"Creality K2 Plus 0.4 nozzle", "Creality K2 Plus 0.6 nozzle"}, "Creality K2 Plus 0.4 nozzle,Creality K2 Plus 0.6 nozzle", 0},
+		{"compatible_printers", []interface{}{"Printer, Inc. 0.4 nozzle"}, "Printer, Inc. 0.4 nozzle", 1},
+		{"filament_ramming_parameters", []interface{}{"120 100 6.6 6.8", "120 100 6.6 6.8"}, "120 100 6.6 6.8;120 100 6.6 6.8", 0},
+		{"chamber_temperature", []interface{}{nil, "35"}, ",35", 1},
+		{"filament_settings_id", []interface{}{}, "", 0},
+	}
+	for _, tt := range tests {
+		got, warnings := SerializeSetting(tt.key, tt.value)
+		if got != tt.want || len(warnings) != tt.warnings {
+			t.Errorf("SerializeSetting(%s, %#v) = %q, %q, want %q with %d warning(s)", tt.key, tt.value, got, warnings, tt.want, tt.warnings)
+		}
+	}
+}
+
+func TestNormalizeSlicerProfileKeepsPerExtruderValues(t *testing.T) {
+	profile := &SlicerFilamentProfile{
+		Name: "Acme PLA",
+		RawData: map[string]interface{}{
+			"filament_notes":     []interface{}{`{"id":"90001","type":"PLA"}`},
+			"nozzle_temperature": []interface{}{"220", "220"},
+			"filament_type":      []interface{}{"PLA", "PLA"},
+			"pressure_advance":   []interface{}{"0.04"},
+		},
+	}
+	data, _, err := NormalizeSlicerProfile(profile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, key := range []string{"nozzle_temperature", "filament_type", "pressure_advance"} {
+		got[key] = data[key]
+	}
+	want := map[string]string{"nozzle_temperature": "220,220", "filament_type": "PLA;PLA", "pressure_advance": "0.04"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeSlicerProfile() = %v, want %v", got, want)
+	}
+	if strings.Contains(data["filament_notes"], "90001") {
+		t.Error("NormalizeSlicerProfile() kept filament_notes as a setting")
+	}
+}
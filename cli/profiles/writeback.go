@@ -0,0 +1,226 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteNotes stores notes as the sync metadata of the slicer profile at filePath: a JSON or
+// INI preset, or a Cura material. Only the notes change; the rest of the file keeps its bytes,
+// so key order and indentation survive. Existing free text in the notes is kept and the
+// metadata is added as a <filament-sync> block.
+func WriteNotes(filePath string, notes *FilamentNotes) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+
+	metadata, err := encodeJSON(notes)
+	if err != nil {
+		return fmt.Errorf("failed to encode notes: %w", err)
+	}
+
+	var updated []byte
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case iniExtension:
+		updated = writeIniNotes(data, string(metadata))
+		if _, err := parseIniPreset(updated); err != nil {
+			return fmt.Errorf("refusing to write %s, the result would not be a valid preset: %w", filePath, err)
+		}
+	case curaExtension:
+		updated, err = writeCuraNotes(data, string(metadata))
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", filePath, err)
+		}
+		if _, err := parseCuraMaterial(updated); err != nil {
+			return fmt.Errorf("refusing to write %s, the result would not be a valid material: %w", filePath, err)
+		}
+	default:
+		updated, err = replaceNotes(data, string(metadata))
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", filePath, err)
+		}
+
+		// Make sure the result still parses before touching the file
+		var check map[string]interface{}
+		if err := json.Unmarshal(updated, &check); err != nil {
+			return fmt.Errorf("refusing to write %s, the result would not be valid JSON: %w", filePath, err)
+		}
+	}
+	if err := os.WriteFile(filePath, updated, stat.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// replaceNotes returns data with the first filament_notes element set to the metadata,
+// adding the key when the profile has none.
+func replaceNotes(data []byte, metadata string) ([]byte, error) {
+	start, end, found, err := topLevelValueSpan(data, "filament_notes")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return insertNotes(data, metadata)
+	}
+
+	value := data[start:end]
+	switch {
+	case value[0] == '"':
+		// A plain string instead of the usual one-element array
+		var existing string
+		if err := json.Unmarshal(value, &existing); err != nil {
+			return nil, err
+		}
+		return splice(data, start, end, notesText(existing, metadata))
+	case value[0] == '[':
+		elementStart, elementEnd, ok, err := firstElementSpan(value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// An empty array gets the metadata as its only element
+			return splice(data, start+1, end-1, metadata)
+		}
+		var existing string
+		if err := json.Unmarshal(value[elementStart:elementEnd], &existing); err != nil {
+			return nil, fmt.Errorf("filament_notes holds a non-text value")
+		}
+		return splice(data, start+elementStart, start+elementEnd, notesText(existing, metadata))
+	default:
+		return nil, fmt.Errorf("filament_notes holds a non-text value")
+	}
+}
+
+// notesText combines the existing notes with the metadata.
+func notesText(existing, metadata string) string {
+	trimmed := strings.TrimSpace(strings.Trim(strings.TrimSpace(existing), `"`))
+	if trimmed == "" {
+		return metadata
+	}
+	return strings.TrimRight(existing, "\n") + "\n<filament-sync>" + metadata + "</filament-sync>"
+}
+
+// splice replaces data[start:end] with text encoded as a JSON string.
+func splice(data []byte, start, end int, text string) ([]byte, error) {
+	encoded, err := encodeJSON(text)
+	if err != nil {
+		return nil, err
+	}
+	result := append([]byte(nil), data[:start]...)
+	result = append(result, encoded...)
+	return append(result, data[end:]...), nil
+}
+
+// insertNotes adds a filament_notes key as the first key of the top-level object, indented
+// like the key that follows it.
+func insertNotes(data []byte, metadata string) ([]byte, error) {
+	open := bytes.IndexByte(data, '{')
+	if open < 0 {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	encoded, err := encodeJSON(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[open+1:]
+	empty := len(bytes.TrimSpace(rest)) > 0 && bytes.TrimSpace(rest)[0] == '}'
+	separator := ","
+	if empty {
+		separator = ""
+	}
+
+	var entry string
+	if newline := bytes.IndexByte(rest, '\n'); newline >= 0 && !empty {
+		// Take the indentation of the next key, e.g. four spaces in slicer exports
+		lineStart := rest[newline+1:]
+		indent := string(lineStart[:len(lineStart)-len(bytes.TrimLeft(lineStart, " \t"))])
+		entry = fmt.Sprintf("\n%s\"filament_notes\": [\n%s%s%s\n%s]%s", indent, indent, indent, encoded, indent, separator)
+	} else {
+		entry = fmt.Sprintf("\"filament_notes\":[%s]%s", encoded, separator)
+	}
+
+	result := append([]byte(nil), data[:open+1]...)
+	result = append(result, entry...)
+	return append(result, rest...), nil
+}
+
+// topLevelValueSpan finds the byte range of the value of key in the top-level JSON object.
+func topLevelValueSpan(data []byte, key string) (int, int, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return 0, 0, false, fmt.Errorf("not a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0, false, err
+		}
+		keyEnd := int(decoder.InputOffset())
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return 0, 0, false, err
+		}
+		if token != key {
+			continue
+		}
+
+		// The value starts at the first non-blank byte after the colon
+		valueEnd := int(decoder.InputOffset())
+		valueStart := keyEnd + bytes.IndexByte(data[keyEnd:], ':') + 1
+		for valueStart < valueEnd && isJSONSpace(data[valueStart]) {
+			valueStart++
+		}
+		return valueStart, valueEnd, true, nil
+	}
+	return 0, 0, false, nil
+}
+
+// firstElementSpan finds the byte range of the first element of a JSON array.
+func firstElementSpan(array []byte) (int, int, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(array))
+	if _, err := decoder.Token(); err != nil {
+		return 0, 0, false, err
+	}
+	if !decoder.More() {
+		return 0, 0, false, nil
+	}
+
+	start := int(decoder.InputOffset())
+	var element json.RawMessage
+	if err := decoder.Decode(&element); err != nil {
+		return 0, 0, false, err
+	}
+	end := int(decoder.InputOffset())
+	for start < end && isJSONSpace(array[start]) {
+		start++
+	}
+	return start, end, true, nil
+}
+
+// isJSONSpace reports whether c is JSON whitespace.
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// encodeJSON marshals value without HTML escaping, as the slicer writes its files.
+func encodeJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -1,6 +1,7 @@
 package scp
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -100,6 +101,50 @@ func (c *SCPClient) CheckRemoteDirectory(targetFileDir string) (string, error) {
 	return targetFileDir, nil
 }
 
+// RunCommand runs a shell command on the remote and returns its standard output.
+func (c *SCPClient) RunCommand(cmd string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sshClient == nil || c.sshClient.Conn == nil || c.sshClient.Conn.LocalAddr() == nil {
+		return nil, fmt.Errorf("SSH client not connected — call Connect() first")
+	}
+
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH session for command: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("remote command %q failed: %w", cmd, err)
+	}
+	return output, nil
+}
+
+// ReadFile returns the contents of a remote file.
+func (c *SCPClient) ReadFile(remotePath string) ([]byte, error) {
+	data, err := c.RunCommand(fmt.Sprintf("cat '%s'", strings.ReplaceAll(remotePath, "\\", "/")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote file %s: %w", remotePath, err)
+	}
+	return data, nil
+}
+
+// FileExists reports whether a regular file exists at remotePath.
+func (c *SCPClient) FileExists(remotePath string) (bool, error) {
+	_, err := c.RunCommand(fmt.Sprintf("test -f '%s'", strings.ReplaceAll(remotePath, "\\", "/")))
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // UploadFile uploads content from an io.Reader to a remote path using raw SCP commands over SSH.
 // It requires the fileName, fileSize, and fileMode for the SCP protocol header.
 func (c *SCPClient) UploadFile(reader io.Reader, remotePath string, fileName string, fileSize int64, fileMode os.FileMode) error {
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RegistryFileName is the name of the ID allocation registry inside the data directory.
+const RegistryFileName = "id_registry.json"
+
+// Registry records every ID handed out by "ids assign", so an ID is never reused even after
+// its profile is deleted or renamed.
+type Registry struct {
+	Allocations []Allocation `json:"allocations"`
+}
+
+// Allocation is one ID given to one profile.
+type Allocation struct {
+	ID         string `json:"id"`
+	Profile    string `json:"profile"` // Preset name at the time of assignment
+	File       string `json:"file"`
+	AssignedAt string `json:"assignedAt"` // RFC 3339
+}
+
+// LoadRegistry reads the ID registry from dir. A missing file yields an empty registry.
+func LoadRegistry(dir string) (*Registry, error) {
+	registry := &Registry{}
+
+	data, err := os.ReadFile(filepath.Join(dir, RegistryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ID registry: %w", err)
+	}
+
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("failed to parse ID registry: %w", err)
+	}
+	return registry, nil
+}
+
+// Save writes the ID registry to dir, creating the directory if needed.
+func (r *Registry) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ID registry: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, RegistryFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write ID registry: %w", err)
+	}
+	return nil
+}
+
+// Allocate returns the lowest 5-digit ID in [first, last] that is neither in the registry nor
+// in used, and records it.
+func (r *Registry) Allocate(first, last int, used map[string]bool, allocation Allocation) (string, error) {
+	taken := make(map[string]bool, len(r.Allocations))
+	for _, existing := range r.Allocations {
+		taken[existing.ID] = true
+	}
+
+	for candidate := first; candidate <= last; candidate++ {
+		id := fmt.Sprintf("%05d", candidate)
+		if taken[id] || used[id] {
+			continue
+		}
+		allocation.ID = id
+		r.Allocations = append(r.Allocations, allocation)
+		return id, nil
+	}
+	return "", fmt.Errorf("no free ID left in the range %05d-%05d", first, last)
+}
+
+// Release forgets an ID allocated in this run, e.g. when writing it into the profile failed.
+func (r *Registry) Release(id string) {
+	for i, allocation := range r.Allocations {
+		if allocation.ID == id {
+			r.Allocations = append(r.Allocations[:i], r.Allocations[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the name of the sync record inside the data directory.
+const FileName = "sync_state.json"
+
+// SyncState records what the tool last pushed to each printer, so later runs can tell
+// whether an entry was changed locally or on the printer since then.
+type SyncState struct {
+	Printers map[string]*PrinterState `json:"printers"` // Keyed by printer host
+}
+
+// PrinterState is the record of the last successful sync to one printer.
+type PrinterState struct {
+	SyncedAt string            `json:"syncedAt"` // Unix timestamp of the sync
+	Entries  map[string]string `json:"entries"`  // Entry fingerprint keyed by Base.ID
+}
+
+// Load reads the sync record from dir. A missing file yields an empty record.
+func Load(dir string) (*SyncState, error) {
+	syncState := &SyncState{Printers: make(map[string]*PrinterState)}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return syncState, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, syncState); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state: %w", err)
+	}
+	if syncState.Printers == nil {
+		syncState.Printers = make(map[string]*PrinterState)
+	}
+	return syncState, nil
+}
+
+// Save writes the sync record to dir, creating the directory if needed.
+func (s *SyncState) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// Printer returns the record for host, or nil if it was never synced.
+func (s *SyncState) Printer(host string) *PrinterState {
+	return s.Printers[host]
+}
+
+// Record stores the fingerprints pushed to host. Entries not part of this sync keep
+// their previous fingerprint, so filtered runs do not forget earlier ones.
+func (s *SyncState) Record(host, syncedAt string, entries map[string]string) {
+	printerState, ok := s.Printers[host]
+	if !ok {
+		printerState = &PrinterState{Entries: make(map[string]string)}
+		s.Printers[host] = printerState
+	}
+	if printerState.Entries == nil {
+		printerState.Entries = make(map[string]string)
+	}
+
+	printerState.SyncedAt = syncedAt
+	for id, fingerprint := range entries {
+		printerState.Entries[id] = fingerprint
+	}
+}
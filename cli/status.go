@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"filament-sync-tool/cli/creality"
+	"filament-sync-tool/cli/state"
+)
+
+// statusRow is one line of the status table.
+type statusRow struct {
+	ID     string
+	Vendor string
+	Name   string
+	Status creality.SyncStatus
+	Source string
+}
+
+// runStatus joins the local profiles with the printer's database by Base.ID and prints
+// the sync state of every entry.
+func runStatus() {
+	fieldMapping := loadMapping()
+	localProfiles, _ := loadLocalProfiles()
+
+	scpClient := connectPrinter()
+	defer scpClient.Close()
+
+	// Stock entries are recognized against the same baseline a sync would use
+	loadBaseline(scpClient)
+	localProfiles = convertLocalProfiles(localProfiles, fieldMapping)
+
+	remoteDBPath := filepath.Join(printerModel.BoxDir, "material_database.json")
+	printerDBBytes, err := scpClient.ReadFile(remoteDBPath)
+	if err != nil {
+		log.Fatalf("Failed to download the printer material database: %v", err)
+	}
+	printerDB, err := creality.LoadDefaultDatabaseFromBytes(printerDBBytes)
+	if err != nil {
+		log.Fatalf("Failed to load the printer material database: %v", err)
+	}
+
+	syncState, err := state.Load(appConfig.DataDir)
+	if err != nil {
+		log.Printf("Warning: ignoring sync state: %v", err)
+		syncState = &state.SyncState{}
+	}
+	lastSync := syncState.Printer(appConfig.PrinterIP)
+
+	var rows []statusRow
+	seen := make(map[string]bool)
+
+	for _, profile := range localProfiles {
+		id := profile.Entry.Base.ID
+		seen[id] = true
+		row := statusRow{ID: id, Vendor: profile.Entry.Base.Brand, Name: profile.Entry.Base.Name, Source: profile.Path}
+
+		// A profile sharing its ID with a stock filament replaces it on sync
+		if creality.FindStockEntry(materialDB, id) != nil || creality.FindStockEntry(printerDB, id) != nil {
+			row.Status = creality.StatusCollision
+			rows = append(rows, row)
+			continue
+		}
+
+		onPrinter := creality.FindEntries(printerDB, id)
+		if len(onPrinter) == 0 {
+			row.Status = creality.StatusLocalOnly
+			rows = append(rows, row)
+			continue
+		}
+
+		localFingerprint, err := creality.EntriesFingerprint(profile.Entries)
+		if err != nil {
+			log.Fatalf("Failed to fingerprint local entry: %v", err)
+		}
+		printerFingerprint, err := creality.EntriesFingerprint(onPrinter)
+		if err != nil {
+			log.Fatalf("Failed to fingerprint printer entry: %v", err)
+		}
+		lastFingerprint := ""
+		if lastSync != nil {
+			lastFingerprint = lastSync.Entries[id]
+		}
+		row.Status = creality.CompareFingerprints(localFingerprint, printerFingerprint, lastFingerprint)
+		rows = append(rows, row)
+	}
+
+	// Entries only on the printer are stock when the bundled database knows them or they
+	// carry no sync metadata (e.g. added by a firmware update); the rest were synced earlier
+	// from profiles that no longer exist locally
+	hiddenStock := 0
+	for _, entry := range printerDB.Result.List {
+		if seen[entry.Base.ID] {
+			continue
+		}
+		seen[entry.Base.ID] = true // One row per ID, even with an entry per nozzle diameter
+		row := statusRow{ID: entry.Base.ID, Vendor: entry.Base.Brand, Name: entry.Base.Name, Source: "printer"}
+		if creality.FindEntry(materialDB, entry.Base.ID) != nil || !creality.HasSyncNotes(&entry) {
+			if !appConfig.ShowAll {
+				hiddenStock++
+				continue
+			}
+			row.Status = creality.StatusStock
+		} else {
+			row.Status = creality.StatusOrphan
+		}
+		rows = append(rows, row)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVENDOR\tNAME\tSTATUS\tSOURCE")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", row.ID, row.Vendor, row.Name, row.Status, row.Source)
+	}
+	w.Flush()
+
+	if hiddenStock > 0 {
+		fmt.Printf("\n%d stock entries not shown, use --all to list them.\n", hiddenStock)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"filament-sync-tool/cli/creality"
+	"filament-sync-tool/cli/printer"
+	"filament-sync-tool/cli/scp"
+	"filament-sync-tool/cli/state"
+)
+
+// runSync pushes the selected custom profiles into the printer's material database.
+func runSync() {
+	fieldMapping := loadMapping()
+	localProfiles, duplicates := loadLocalProfiles()
+	if len(localProfiles) == 0 {
+		log.Println("No profiles selected for sync.")
+		return // Exit if no profiles to sync
+	}
+
+	// Establish and defer close SSH connection once for transfer operations
+	scpClient := connectPrinter()
+	defer scpClient.Close()
+
+	// Never overwrite the material database while a job may be reading it
+	if !printerReadyForSync(scpClient) {
+		log.Println("Sync postponed: the printer is busy or its state is unknown. It will run again on the next export, or use --force to override.")
+		return
+	}
+
+	// Pick the baseline the custom entries are merged into
+	loadBaseline(scpClient)
+	localProfiles = convertLocalProfiles(localProfiles, fieldMapping)
+
+	// Stock filaments must not be replaced by accident; the printer may know more than the baseline
+	var printerDB *creality.MaterialDatabase
+	printerDBBytes, err := scpClient.ReadFile(filepath.Join(printerModel.BoxDir, "material_database.json"))
+	if err == nil {
+		printerDB, err = creality.LoadDefaultDatabaseFromBytes(printerDBBytes)
+	}
+	if err != nil {
+		log.Printf("Warning: checking ID collisions against the baseline only, the printer database is unreadable: %v", err)
+	}
+	localProfiles = applyCollisionPolicy(localProfiles, duplicates, printerDB)
+	if len(localProfiles) == 0 {
+		log.Println("No profiles left to sync.")
+		return
+	}
+
+	// Update in-memory databases with the new/updated entries
+	currentTimestamp := fmt.Sprintf("%d", time.Now().Unix())
+	fingerprints := make(map[string]string)
+	for _, profile := range localProfiles {
+		creality.ReplaceProfileEntries(materialDB, profile.Entries, currentTimestamp)
+		creality.UpdateOptions(materialOptions, profile.Notes)
+
+		fingerprint, err := creality.EntriesFingerprint(profile.Entries)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		fingerprints[profile.Entry.Base.ID] = fingerprint
+	}
+
+	// --- Prepare Data for SCP (from memory) ---
+	updatedDBBytes, err := creality.MarshalDatabase(materialDB)
+	if err != nil {
+		log.Fatalf("Failed to marshal updated material database to bytes: %v", err)
+	}
+
+	updatedOptBytes, err := creality.MarshalOptions(materialOptions)
+	if err != nil {
+		log.Fatalf("Failed to marshal updated material options to bytes: %v", err)
+	}
+
+	// --- SCP Transfer to Printer ---
+	log.Println("Initiating SCP transfer to printer (from memory)...")
+
+	// Check if the remote directory exists
+	_, err = scpClient.CheckRemoteDirectory(printerModel.BoxDir)
+	if err != nil {
+		log.Fatalf("Error checking remote directory %s: %v", printerModel.BoxDir, err)
+	}
+
+	// Upload material_database.json and material_option.json directly from bytes
+	if err := uploadToBox(scpClient, "material_database.json", updatedDBBytes); err != nil {
+		log.Fatalf("Failed to upload material_database.json to printer: %v", err)
+	}
+	log.Println("Uploaded material_database.json to printer.")
+
+	if err := uploadToBox(scpClient, "material_option.json", updatedOptBytes); err != nil {
+		log.Fatalf("Failed to upload material_option.json to printer: %v", err)
+	}
+	log.Println("Uploaded material_option.json to printer.")
+
+	log.Println("Filament profiles synchronized successfully with the printer!")
+
+	// Remember what was pushed so "status" can tell local edits from printer-side ones
+	syncState, err := state.Load(appConfig.DataDir)
+	if err != nil {
+		log.Printf("Warning: could not record sync state: %v", err)
+		return
+	}
+	syncState.Record(appConfig.PrinterIP, currentTimestamp, fingerprints)
+	if err := syncState.Save(appConfig.DataDir); err != nil {
+		log.Printf("Warning: could not record sync state: %v", err)
+	}
+}
+
+// statusPollInterval is how often the printer state is polled while waiting for a job to finish.
+const statusPollInterval = 30 * time.Second
+
+// queryPrinterState asks the printer status API for the job state, falling back to
+// a probe over the existing SSH connection when the API is not reachable from here.
+func queryPrinterState(scpClient *scp.SCPClient) (printer.PrintState, error) {
+	statusURL := appConfig.StatusURL
+	if statusURL == "" {
+		statusURL = printer.StatusURL(appConfig.PrinterIP)
+	}
+
+	state, err := printer.QueryPrintState(statusURL, 5*time.Second)
+	if err == nil {
+		return state, nil
+	}
+	log.Printf("Status API not reachable (%v), probing over SSH instead", err)
+
+	return printer.ProbePrintState(scpClient)
+}
+
+// printerReadyForSync reports whether the databases can be written now.
+// With --force the state is not checked; with --wait the printer is polled until
+// the job finishes or the wait expires. A state that cannot be determined counts as busy.
+func printerReadyForSync(scpClient *scp.SCPClient) bool {
+	if appConfig.Force {
+		log.Println("--force set, skipping printer state check.")
+		return true
+	}
+
+	deadline := time.Now().Add(appConfig.Wait)
+	for {
+		state, err := queryPrinterState(scpClient)
+		switch {
+		case err != nil:
+			log.Printf("Could not determine printer state: %v", err)
+		case state.Idle():
+			log.Printf("Printer state: %s", state)
+			return true
+		case state.Busy():
+			log.Printf("Printer state: %s", state)
+		default:
+			log.Printf("Printer state %q is not known to be idle.", state)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			log.Println("Refusing to overwrite the material database while a job may be running.")
+			return false
+		}
+
+		log.Printf("Waiting for the job to finish (%s left)...", remaining.Round(time.Second))
+		time.Sleep(min(statusPollInterval, remaining))
+	}
+}